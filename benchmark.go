@@ -1,6 +1,8 @@
-package main
+package benchdis
 
 import (
+	"context"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -10,14 +12,22 @@ import (
 // BenchmarkResult is the result for one benchmark test
 type BenchmarkResult struct {
 	BenchTestName string  `json:"test" yaml:"test"`
+	Partial       bool    `json:"partial,omitempty" yaml:"partial,omitempty"`
+	Sweep         string  `json:"sweep,omitempty" yaml:"sweep,omitempty"`
+	Payload       string  `json:"payload,omitempty" yaml:"payload,omitempty"`
 	QPS           float64 `json:"qps,omitempty" yaml:"qps,omitempty"`
 	MinLatency    float64 `json:"min,omitempty" yaml:"min,omitempty"`
 	AvgLatency    float64 `json:"avg,omitempty" yaml:"avg,omitempty"`
-	MedianLatency float64 `json:"median,omitempty" yaml:"median,omitempty"`
-	P75Latency    float64 `json:"p75,omitempty" yaml:"p75,omitempty"`
-	P90Latency    float64 `json:"p90,omitempty" yaml:"p90,omitempty"`
-	P99Latency    float64 `json:"p99,omitempty" yaml:"p99,omitempty"`
 	MaxLatency    float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	ErrorCount    int     `json:"error_count,omitempty" yaml:"error_count,omitempty"`
+
+	Percentiles map[string]float64    `json:"percentiles,omitempty" yaml:"percentiles,omitempty"`
+	Errors      map[string]*ErrorStat `json:"errors,omitempty" yaml:"errors,omitempty"`
+	TimeSeries  []*IntervalStat       `json:"timeseries,omitempty" yaml:"timeseries,omitempty"`
+	Histogram   []*HistogramBucket    `json:"histogram,omitempty" yaml:"histogram,omitempty"`
+
+	Summary map[string]*MetricSummary `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Runs    []*BenchmarkResult        `json:"runs,omitempty" yaml:"runs,omitempty"`
 }
 
 // Benchmark encapsulates all the benchmarking params
@@ -29,25 +39,68 @@ type Benchmark struct {
 	End           time.Time
 	requests      []int
 	latencies     [][]float64
+	buckets       [][]*intervalBucket
 	errorCount    int32
+	errorStats    map[string]*ErrorStat
+	errMutex      sync.Mutex
+	runs          []*BenchmarkResult
+	promMetrics   *testMetrics
+	faults        []*FaultPhase
+	logger        *Logger
 }
 
-// var Benchmarks map[string]*Benchmark
+// Benchmarks is the registry of the benchmarks of a run, which keeps them in the order of
+// the tests
+type Benchmarks struct {
+	order  []string
+	byTest map[string]*Benchmark
+}
 
 // InitializeBenchmarks initializes the test set of benchmarks
-func InitializeBenchmarks(conf *Config, tests []string) map[string]*Benchmark {
-	bnchMks := make(map[string]*Benchmark, len(tests))
+func InitializeBenchmarks(conf *Config, tests []string, logger *Logger) *Benchmarks {
+	bnchMks := Benchmarks{
+		order:  make([]string, 0, len(tests)),
+		byTest: make(map[string]*Benchmark, len(tests)),
+	}
 	for _, t := range tests {
+		if _, ok := bnchMks.byTest[t]; ok {
+			continue
+		}
 		b := Benchmark{
-			Config:          conf,
-			BenchmarkResult: &BenchmarkResult{BenchTestName: t},
-			BenchTestName:   t,
-			requests:        make([]int, conf.NClients),
-			latencies:       make([][]float64, conf.NClients),
+			Config:        conf,
+			BenchTestName: t,
+			logger:        logger,
 		}
-		bnchMks[t] = &b
+		b.Reset()
+		bnchMks.order = append(bnchMks.order, t)
+		bnchMks.byTest[t] = &b
+	}
+	return &bnchMks
+}
+
+// Get returns the benchmark of a test
+func (bs *Benchmarks) Get(test string) *Benchmark {
+	return bs.byTest[test]
+}
+
+// All returns all the benchmarks in the order of the tests
+func (bs *Benchmarks) All() []*Benchmark {
+	all := make([]*Benchmark, 0, len(bs.order))
+	for _, t := range bs.order {
+		all = append(all, bs.byTest[t])
 	}
-	return bnchMks
+	return all
+}
+
+// Reset clears everything recorded for the current run so that the test can be run again.
+// The results of the runs completed earlier are retained.
+func (b *Benchmark) Reset() {
+	b.BenchmarkResult = &BenchmarkResult{BenchTestName: b.BenchTestName}
+	b.requests = make([]int, b.NClients)
+	b.latencies = make([][]float64, b.NClients)
+	b.buckets = make([][]*intervalBucket, b.NClients)
+	b.errorStats = make(map[string]*ErrorStat)
+	atomic.StoreInt32(&b.errorCount, 0)
 }
 
 // // GetBenchmark gets the Benchmark object associated with a test
@@ -63,17 +116,61 @@ func InitializeBenchmarks(conf *Config, tests []string) map[string]*Benchmark {
 // 	return &bx
 // }
 
-// Mark an execution of a function for benchmarking
-func (b *Benchmark) Mark(clientId, reqId int, fn func() (interface{}, error)) (interface{}, error) {
+// Mark an execution of a function for benchmarking. Nothing is executed once the context is
+// done, and requests which fail because the run was cancelled are not counted as errors.
+func (b *Benchmark) Mark(ctx context.Context, clientId, reqId int,
+	fn func() (interface{}, error)) (interface{}, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	st := time.Now()
 	res, err := fn()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	b.markRequest(clientId, reqId, st, time.Now(), err)
 	if err != nil {
-		logger.Debugf("Error in benchmarking: %s", err.Error())
-		atomic.AddInt32(&b.errorCount, 1)
 		return nil, err
 	}
+	return res, nil
+}
+
+// MarkPipeline runs a pipeline of requests and records the time of the whole pipeline as the
+// latency of each of its requests, since the replies only arrive once all of them are sent.
+func (b *Benchmark) MarkPipeline(ctx context.Context, clientId int, reqIds []int,
+	fn func() []Reply) []Reply {
+
+	if err := ctx.Err(); err != nil {
+		return failedReplies(make([]Reply, len(reqIds)), err)
+	}
+	st := time.Now()
+	replies := fn()
 	end := time.Now()
+	for i, reply := range replies {
+		if reply.Err != nil && ctx.Err() != nil {
+			replies[i].Err = ctx.Err()
+			continue
+		}
+		b.markRequest(clientId, reqIds[i], st, end, reply.Err)
+	}
+	return replies
+}
+
+// markRequest records the outcome of a request sent at st which got its reply at end
+func (b *Benchmark) markRequest(clientId, reqId int, st, end time.Time, err error) {
+	if err != nil {
+		b.logger.Debugf("Error in benchmarking: %s", err.Error())
+		atomic.AddInt32(&b.errorCount, 1)
+		b.markError(err)
+		b.markInterval(clientId, end, 0, true)
+		return
+	}
 	latency := float64(end.Sub(st).Microseconds()) / float64(1000)
+	if b.promMetrics != nil {
+		b.promMetrics.observe(end.Sub(st))
+	}
+	b.markInterval(clientId, end, latency, false)
 
 	if b.Latency {
 		b.markLatency(clientId, reqId, latency)
@@ -81,7 +178,6 @@ func (b *Benchmark) Mark(clientId, reqId int, fn func() (interface{}, error)) (i
 	if b.Config.QPS {
 		b.markQPS(clientId)
 	}
-	return res, err
 }
 
 func (b *Benchmark) markLatency(clientId, reqId int, latency float64) {
@@ -107,11 +203,25 @@ func (b *Benchmark) EndBenchmark() {
 	b.End = time.Now()
 }
 
+// Label is the name of the test in the reports, with the level of the sweep it ran at, if
+// any, and which flags partial results
+func (br *BenchmarkResult) Label() string {
+	label := br.BenchTestName
+	if len(br.Sweep) > 0 {
+		label += " " + br.Sweep
+	}
+	if br.Partial {
+		return label + " (partial)"
+	}
+	return label
+}
+
 // Record records the benchmark results from the internal representation into it's
 // BenchmarkResult object.
 func (b *Benchmark) Record(test string) {
 
 	b.BenchTestName = test
+	b.BenchmarkResult.Payload = b.Config.Payload
 	if b.Config.QPS {
 		reqTot := 0
 		for _, r := range b.requests {
@@ -132,9 +242,17 @@ func (b *Benchmark) Record(test string) {
 		rawLatencies := stats.LoadRawData(latencies)
 		b.MinLatency, _ = rawLatencies.Min()
 		b.AvgLatency, _ = rawLatencies.Mean()
-		b.MedianLatency, _ = rawLatencies.Median()
-		b.P90Latency, _ = rawLatencies.Percentile(90)
-		b.P99Latency, _ = rawLatencies.Percentile(99)
 		b.MaxLatency, _ = rawLatencies.Max()
+		b.BenchmarkResult.Percentiles = make(map[string]float64, len(b.Config.Percentiles))
+		for _, p := range b.Config.Percentiles {
+			b.BenchmarkResult.Percentiles[percentileKey(p)], _ = rawLatencies.Percentile(p)
+		}
+		b.Histogram = buildHistogram(latencies)
+	}
+	b.ErrorCount = int(atomic.LoadInt32(&b.errorCount))
+	if len(b.errorStats) > 0 {
+		b.Errors = b.errorStats
 	}
+	b.recordTimeSeries()
+	b.runs = append(b.runs, b.BenchmarkResult)
 }
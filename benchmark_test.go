@@ -0,0 +1,274 @@
+package benchdis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func newTestBenchmark(conf *Config, test string) *Benchmark {
+	return InitializeBenchmarks(conf, []string{test}, nil).Get(test)
+}
+
+func TestMarkCountsErrors(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NClients = 2
+	conf.NReqs = 10
+	b := newTestBenchmark(&conf, "set")
+	b.StartBenchmark()
+	ctx := context.Background()
+	fails := []error{
+		redis.Error("READONLY You can't write against a read only replica."),
+		redis.Error("READONLY You can't write against a read only replica."),
+		redis.Error("OOM command not allowed when used memory > 'maxmemory'."),
+		io.EOF,
+	}
+	for i, err := range fails {
+		err := err
+		_, got := b.Mark(ctx, i%2, i, func() (interface{}, error) { return nil, err })
+		if got != err {
+			t.Errorf("Mark returned %v, want %v", got, err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		b.Mark(ctx, i%2, i, func() (interface{}, error) { return "OK", nil })
+	}
+	// requests failing once the run is cancelled are not errors of the server
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.Mark(cancelled, 0, 0, func() (interface{}, error) { return nil, io.EOF })
+	b.EndBenchmark()
+	b.Record("set")
+
+	if b.ErrorCount != len(fails) {
+		t.Errorf("ErrorCount = %d, want %d", b.ErrorCount, len(fails))
+	}
+	want := map[string]int{"readonly": 2, "oom": 1, "conn_closed": 1}
+	got := make(map[string]int)
+	for cat, es := range b.Errors {
+		got[cat] = es.Count
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Errors = %v, want %v", got, want)
+	}
+	if sample := b.Errors["oom"].Sample; sample != fails[2].Error() {
+		t.Errorf("Sample of oom = %q, want %q", sample, fails[2].Error())
+	}
+	if cats := errorCategories(b.BenchmarkResult); cats[0] != "readonly" {
+		t.Errorf("errorCategories = %v, want readonly first", cats)
+	}
+	if b.BenchmarkResult.QPS <= 0 {
+		t.Errorf("QPS = %v, want the rate of the 6 successful requests", b.BenchmarkResult.QPS)
+	}
+}
+
+func TestMarkPipeline(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NClients = 1
+	b := newTestBenchmark(&conf, "get")
+	b.StartBenchmark()
+	replies := b.MarkPipeline(context.Background(), 0, []int{1, 2, 3}, func() []Reply {
+		time.Sleep(time.Millisecond)
+		return []Reply{{Value: "a"}, {Err: redis.Error("LOADING loading")}, {Value: "c"}}
+	})
+	b.EndBenchmark()
+	b.Record("get")
+	if len(replies) != 3 || replies[0].Value != "a" {
+		t.Errorf("MarkPipeline returned %v", replies)
+	}
+	if b.ErrorCount != 1 || b.Errors["loading"] == nil {
+		t.Errorf("ErrorCount = %d, Errors = %v, want one loading error", b.ErrorCount,
+			b.Errors)
+	}
+	// every request of the pipeline waits for the whole pipeline
+	if b.MinLatency < 1 || b.MinLatency != b.MaxLatency {
+		t.Errorf("Latencies are %v to %v, want the pipeline latency for both", b.MinLatency,
+			b.MaxLatency)
+	}
+}
+
+func TestRecordPercentiles(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NClients = 2
+	conf.Percentiles = []float64{50, 90, 99, 99.9}
+	b := newTestBenchmark(&conf, "get")
+	b.StartBenchmark()
+	for i := 1; i <= 100; i++ {
+		b.markLatency(i%2, i, float64(i))
+	}
+	b.EndBenchmark()
+	b.Record("get")
+
+	want := map[string]float64{"p50": 50, "p90": 90, "p99": 99, "p99.9": 99.5}
+	for k, v := range want {
+		if b.BenchmarkResult.Percentiles[k] != v {
+			t.Errorf("%s = %v, want %v", k, b.BenchmarkResult.Percentiles[k], v)
+		}
+	}
+	if b.MinLatency != 1 || b.MaxLatency != 100 || b.AvgLatency != 50.5 {
+		t.Errorf("Min, avg and max = %v, %v, %v, want 1, 50.5, 100", b.MinLatency,
+			b.AvgLatency, b.MaxLatency)
+	}
+	total := 0
+	for _, hb := range b.Histogram {
+		total += hb.Count
+	}
+	if total != 100 {
+		t.Errorf("Histogram has %d requests, want 100", total)
+	}
+}
+
+func TestPercentileKeys(t *testing.T) {
+	if k := percentileKey(99.9); k != "p99.9" {
+		t.Errorf("percentileKey(99.9) = %s", k)
+	}
+	if p, err := percentileValue("p99.9"); err != nil || p != 99.9 {
+		t.Errorf("percentileValue(p99.9) = %v, %v", p, err)
+	}
+	brs := []*BenchmarkResult{
+		{Percentiles: map[string]float64{"p99": 1, "p9": 1}},
+		{Percentiles: map[string]float64{"p99.9": 1, "p50": 1}},
+	}
+	want := []string{"p9", "p50", "p99", "p99.9"}
+	if keys := percentileKeys(brs); !reflect.DeepEqual(keys, want) {
+		t.Errorf("percentileKeys = %v, want %v", keys, want)
+	}
+}
+
+func TestSummarizeRuns(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NClients = 1
+	b := newTestBenchmark(&conf, "set")
+	for run := 1; run <= 2; run++ {
+		b.Reset()
+		b.StartBenchmark()
+		for i := 1; i <= 10; i++ {
+			b.markLatency(0, i, float64(i*run))
+		}
+		b.markError(redis.Error("BUSY busy"))
+		b.EndBenchmark()
+		b.Record("set")
+	}
+	b.Summarize()
+	if len(b.Runs) != 2 {
+		t.Fatalf("Summary has %d runs, want 2", len(b.Runs))
+	}
+	if b.Errors["busy"].Count != 2 {
+		t.Errorf("Summary has %d busy errors, want 2", b.Errors["busy"].Count)
+	}
+	if b.MinLatency != 1 || b.MaxLatency != 20 {
+		t.Errorf("Summary latencies are %v to %v, want 1 to 20", b.MinLatency, b.MaxLatency)
+	}
+	if b.BenchmarkResult.Percentiles["p50"] != 7.5 || b.Summary["p50"] == nil {
+		t.Errorf("Summary p50 = %v, want the mean 7.5 of the runs", b.BenchmarkResult.Percentiles["p50"])
+	}
+}
+
+func TestSummarizeLeavesOutInterruptedRun(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NClients = 1
+	b := newTestBenchmark(&conf, "set")
+	for run := 1; run <= 3; run++ {
+		b.Reset()
+		b.StartBenchmark()
+		for i := 1; i <= 10; i++ {
+			b.markLatency(0, i, float64(run))
+		}
+		b.EndBenchmark()
+		b.Record("set")
+		b.BenchmarkResult.Partial = run == 3
+	}
+	b.Summarize()
+	if len(b.Runs) != 2 || b.MaxLatency != 2 {
+		t.Errorf("Summary has %d runs up to %vms, want the 2 complete runs", len(b.Runs),
+			b.MaxLatency)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := map[error]string{
+		redis.Error("MOVED 3999 127.0.0.1:6381"):      "moved",
+		redis.Error("ERR unknown command"):            "server",
+		MemcachedError("SERVER_ERROR out of memory"):  "oom",
+		MemcachedError("CLIENT_ERROR bad data chunk"): "client_error",
+		syscall.ECONNREFUSED:                          "conn_refused",
+		io.ErrUnexpectedEOF:                           "conn_closed",
+		redis.ErrPoolExhausted:                        "pool_exhausted",
+		errors.New("something else"):                  "other",
+	}
+	for err, want := range tests {
+		if got := classifyError(err); got != want {
+			t.Errorf("classifyError(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
+
+func TestRecordTimeSeriesMergesTail(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NClients = 1
+	conf.Interval = 100 * time.Millisecond
+	b := newTestBenchmark(&conf, "set")
+	b.Start = time.Now()
+	b.End = b.Start.Add(205 * time.Millisecond)
+	marks := map[time.Duration]int{50 * time.Millisecond: 10, 150 * time.Millisecond: 10,
+		203 * time.Millisecond: 2}
+	for at, n := range marks {
+		for i := 0; i < n; i++ {
+			b.markInterval(0, b.Start.Add(at), 0.5, false)
+		}
+	}
+	b.recordTimeSeries()
+
+	// the 5ms tail is part of the second interval rather than a spike of 400 QPS
+	if len(b.TimeSeries) != 2 {
+		t.Fatalf("Time series has %d intervals, want 2", len(b.TimeSeries))
+	}
+	if qps := b.TimeSeries[0].QPS; qps < 99.9 || qps > 100.1 {
+		t.Errorf("First interval has %v QPS, want 100", qps)
+	}
+	if qps := b.TimeSeries[1].QPS; qps < 114 || qps > 115 {
+		t.Errorf("Last interval has %v QPS, want 12 requests in 105ms", qps)
+	}
+
+	// a run shorter than an interval is a single interval over the run
+	b.Reset()
+	b.Start = time.Now()
+	b.End = b.Start.Add(50 * time.Millisecond)
+	b.markInterval(0, b.Start.Add(10*time.Millisecond), 0.5, false)
+	b.recordTimeSeries()
+	if len(b.TimeSeries) != 1 || b.TimeSeries[0].QPS < 19.9 || b.TimeSeries[0].QPS > 20.1 {
+		t.Errorf("Short run has time series %+v, want 20 QPS", b.TimeSeries[0])
+	}
+}
+
+func TestUnmarshalLegacyPercentiles(t *testing.T) {
+	var fromJSON, fromYAML []*BenchmarkResult
+	err := json.Unmarshal([]byte(`[{"test":"set","qps":1000,"median":0.5,"p90":0.9,"p99":1.2}]`),
+		&fromJSON)
+	if err != nil || len(fromJSON) != 1 {
+		t.Fatalf("Legacy json result does not parse: %v", err)
+	}
+	want := map[string]float64{"p50": 0.5, "p90": 0.9, "p99": 1.2}
+	if !reflect.DeepEqual(fromJSON[0].Percentiles, want) {
+		t.Errorf("Legacy json result has percentiles %v, want %v", fromJSON[0].Percentiles, want)
+	}
+
+	// the percentiles saved by newer versions win over the legacy fields
+	err = yaml.Unmarshal([]byte("- test: set\n  qps: 1000\n  median: 0.5\n  p90: 0.9\n"+
+		"  p99: 1.2\n  percentiles:\n    p99: 1.5\n"), &fromYAML)
+	if err != nil || len(fromYAML) != 1 {
+		t.Fatalf("Legacy yaml result does not parse: %v", err)
+	}
+	want = map[string]float64{"p50": 0.5, "p90": 0.9, "p99": 1.5}
+	if !reflect.DeepEqual(fromYAML[0].Percentiles, want) {
+		t.Errorf("Legacy yaml result has percentiles %v, want %v", fromYAML[0].Percentiles, want)
+	}
+}
@@ -1,14 +1,18 @@
-package main
+package benchdis
 
 import (
 	"bytes"
 	"fmt"
-	"html/template"
 	"math/rand"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 )
 
+var tagPattern = regexp.MustCompile(`^[\w-]*$`)
+var tmplPattern = regexp.MustCompile(`{{[^}]*}}`)
+
 type params struct {
 	Tag     string
 	RandInt int
@@ -28,11 +32,12 @@ type ScenarioSetup struct {
 	keys      []string
 	tag       string
 	data      []byte
+	logger    *Logger
 }
 
 // NewScenarioSetup initializes all the test case scenarios
-func NewScenarioSetup(conf *Config) *ScenarioSetup {
-	sc := ScenarioSetup{Config: conf}
+func NewScenarioSetup(conf *Config, logger *Logger) (*ScenarioSetup, error) {
+	sc := ScenarioSetup{Config: conf, logger: logger}
 	rand.Seed(time.Now().UnixNano())
 	sc.initializeScenarios()
 	sc.integers = make([]int, conf.NReqs)
@@ -45,10 +50,21 @@ func NewScenarioSetup(conf *Config) *ScenarioSetup {
 		sc.keys[i] = randomKey(rand.Intn(15))
 	}
 
-	sc.tag = fmt.Sprintf("%d", time.Now().UTC().Unix())
-	sc.data = make([]byte, conf.ReqSize)
-	rand.Read(sc.data)
-	return &sc
+	if len(conf.Tag) == 0 {
+		conf.Tag = newTag()
+	}
+	sc.tag = conf.Tag
+	data, err := generatePayload(conf.Payload, conf.ReqSize)
+	if err != nil {
+		return nil, err
+	}
+	sc.data = data
+	return &sc, nil
+}
+
+// newTag is the tag of a run without one, its start time
+func newTag() string {
+	return fmt.Sprintf("%d", time.Now().UTC().Unix())
 }
 
 func (sc *ScenarioSetup) initializeScenarios() {
@@ -157,10 +173,47 @@ func (sc *ScenarioSetup) ToRedis(test string, reqIdx int) (string, []interface{}
 		bbuf := bytes.Buffer{}
 		err := tmplt.Execute(&bbuf, p)
 		if err != nil {
-			logger.Debugf("Error in detemplatizing: %s", err.Error())
+			sc.logger.Debugf("Error in detemplatizing: %s", err.Error())
 		}
 		bts := bbuf.Bytes()
 		res[i] = bts
 	}
 	return rcmd.cmd, res, nil
 }
+
+// testCarriesData checks whether the requests of a test send the data payload
+func testCarriesData(test string) bool {
+	sc := ScenarioSetup{}
+	sc.initializeScenarios()
+	for _, arg := range sc.scenarios[test].args {
+		if strings.Contains(arg, "{{.Data}}") {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidTag checks that a key tag has only letters, digits, - and _, so that it can be matched
+// with SCAN safely
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// tagPatterns returns the SCAN patterns of the keys that the tests write with the tag
+func tagPatterns(tag string) []string {
+	sc := ScenarioSetup{}
+	sc.initializeScenarios()
+	patterns := make([]string, 0)
+	for _, t := range SupportedTests {
+		args := sc.scenarios[t].args
+		if len(args) == 0 || !strings.Contains(args[0], "{{.Tag}}") {
+			continue
+		}
+		p := strings.ReplaceAll(args[0], "{{.Tag}}", tag)
+		p = tmplPattern.ReplaceAllString(p, "*")
+		if !searchInList(p, patterns) {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,94 @@
+package benchdis
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestScenarioSetup(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NReqs = 10
+	conf.ReqSize = 32
+	conf.Tag = "unit"
+	sc, err := NewScenarioSetup(&conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range SupportedTests {
+		cmd, args, err := sc.ToRedis(test, 3)
+		if err != nil {
+			t.Fatalf("ToRedis(%s) failed: %v", test, err)
+		}
+		if cmd != strings.ToUpper(test) {
+			t.Errorf("ToRedis(%s) = %s, want %s", test, cmd, strings.ToUpper(test))
+		}
+		if test == "ping" {
+			if len(args) != 0 {
+				t.Errorf("ToRedis(ping) has args %v", args)
+			}
+			continue
+		}
+		key := string(args[0].([]byte))
+		if !strings.Contains(key, ":unit") || strings.Contains(key, "{{") {
+			t.Errorf("Key of %s is %q, want it tagged with unit", test, key)
+		}
+		matched := false
+		for _, p := range tagPatterns("unit") {
+			if ok, _ := path.Match(p, key); ok {
+				matched = true
+			}
+		}
+		if !matched {
+			t.Errorf("Key %q of %s matches none of the cleanup patterns", key, test)
+		}
+		if testCarriesData(test) {
+			data := args[len(args)-1].([]byte)
+			if len(data) != conf.ReqSize || !bytes.Equal(data, sc.data) {
+				t.Errorf("Data of %s has %d bytes, want the %d bytes payload", test, len(data),
+					conf.ReqSize)
+			}
+		}
+	}
+
+	// the same request always maps to the same key, so that get reads what set wrote
+	_, set, _ := sc.ToRedis("set", 13)
+	_, get, _ := sc.ToRedis("get", 3)
+	if !bytes.Equal(set[0].([]byte), get[0].([]byte)) {
+		t.Errorf("set and get of the same request use keys %s and %s", set[0], get[0])
+	}
+
+	if _, _, err := sc.ToRedis("nosuch", 1); err == nil {
+		t.Error("ToRedis of an unknown test did not fail")
+	}
+}
+
+func TestScenarioSetupTag(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NReqs = 1
+	if _, err := NewScenarioSetup(&conf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Tag) == 0 || !ValidTag(conf.Tag) {
+		t.Errorf("Generated tag %q is not valid", conf.Tag)
+	}
+	for tag, valid := range map[string]bool{"run-1_a": true, "": true, "a*": false, "a:b": false} {
+		if ValidTag(tag) != valid {
+			t.Errorf("ValidTag(%q) = %v, want %v", tag, !valid, valid)
+		}
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	for _, mode := range SupportedPayloads {
+		data, err := generatePayload(mode, 100)
+		if err != nil || len(data) != 100 {
+			t.Errorf("Payload %s has %d bytes, %v, want 100 bytes", mode, len(data), err)
+		}
+	}
+	if validPayload("file:") || validPayload("nosuch") {
+		t.Error("Invalid payloads were accepted")
+	}
+}
@@ -0,0 +1,111 @@
+package benchdis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/montanaflynn/stats"
+)
+
+// Cleanup deletes the keys written by a run with the tag, for runs which were killed before
+// they could clean up after themselves. The keys are returned, and only listed if dryRun is
+// set.
+func Cleanup(conf *Config, tag string, dryRun bool) ([]string, error) {
+	if len(tag) == 0 || !ValidTag(tag) {
+		return nil, fmt.Errorf("Tag %q is not valid, should only have letters, digits, - and _",
+			tag)
+	}
+	if conf.Driver != "redis" {
+		return nil, fmt.Errorf("Cleanup is not supported by the %s driver", conf.Driver)
+	}
+	pool := createRedisPool(conf)
+	defer pool.Close()
+	conn := pool.Get()
+	defer conn.Close()
+	keys := make([]string, 0)
+	for _, pattern := range tagPatterns(tag) {
+		matched, err := scanKeys(conn, pattern)
+		if err != nil {
+			return keys, fmt.Errorf("Cannot scan keys matching %s: %s", pattern, err.Error())
+		}
+		for _, k := range matched {
+			if !dryRun {
+				if _, err := conn.Do("DEL", k); err != nil {
+					return keys, fmt.Errorf("Cannot delete key %s: %s", k, err.Error())
+				}
+			}
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// scanKeys returns all the keys matching the pattern using SCAN, without blocking the server
+// as KEYS does
+func scanKeys(conn redis.Conn, pattern string) ([]string, error) {
+	keys := make([]string, 0)
+	cursor := "0"
+	for {
+		res, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 1000))
+		if err != nil {
+			return nil, err
+		}
+		if len(res) != 2 {
+			return nil, fmt.Errorf("Unexpected reply to SCAN: %v", res)
+		}
+		if cursor, err = redis.String(res[0], nil); err != nil {
+			return nil, err
+		}
+		batch, err := redis.Strings(res[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, batch...)
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// ProbeResult is the outcome of probing a server, with the latencies of PING in milliseconds
+type ProbeResult struct {
+	Target        string
+	ServerVersion string
+	Count         int
+	MinLatency    float64
+	AvgLatency    float64
+	MaxLatency    float64
+}
+
+// Probe checks that the server can be reached, and measures the latency of count PINGs along
+// with the version of the server
+func Probe(conf *Config, count int) (*ProbeResult, error) {
+	driver := newDriver(conf)
+	defer driver.Close()
+	conn, err := driver.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	pr := ProbeResult{Target: redisAddress(conf), Count: count}
+	if len(conf.URL) > 0 {
+		pr.Target = redactURL(conf.URL)
+	}
+	latencies := make([]float64, 0, count)
+	for i := 0; i < count; i++ {
+		st := time.Now()
+		if _, err := conn.Execute(&Command{Name: "PING"}); err != nil {
+			return nil, fmt.Errorf("Cannot reach %s: %s", pr.Target, err.Error())
+		}
+		latencies = append(latencies, float64(time.Since(st).Microseconds())/1000)
+	}
+	if v, ok := conn.(versioner); ok {
+		pr.ServerVersion, _ = v.ServerVersion()
+	}
+	raw := stats.LoadRawData(latencies)
+	pr.MinLatency, _ = raw.Min()
+	pr.AvgLatency, _ = raw.Mean()
+	pr.MaxLatency, _ = raw.Max()
+	return &pr, nil
+}
@@ -1,7 +1,9 @@
-package main
+package benchdis
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
+	"strings"
 	"sync"
 
 	"github.com/gomodule/redigo/redis"
@@ -9,11 +11,14 @@ import (
 )
 
 // RedisClient is the struct that encapsulates a single client for benchmarking.
-// Each client has a pool of size Config.pool and sends Config.nreqs request
+// Each client shares the driver, which pools Config.pool connections, and sends
+// Config.nreqs request
 type RedisClient struct {
-	Pool      *redis.Pool
+	driver    Driver
+	pipeline  int
 	scenarios *ScenarioSetup
 	keyspace  [][]byte
+	logger    *Logger
 }
 
 type Client struct {
@@ -21,93 +26,120 @@ type Client struct {
 	id int
 }
 
-// func nextRequest() int {
-// 	Global.Mutex.Lock()
-// 	defer Global.Mutex.Unlock()
-// 	Global.ReqCounter++
-// 	r := Global.ReqCounter
-// 	if r > GlobalConfig.NReqs {
-// 		return -1
-// 	}
-// 	return r
-// }
-
-// func reset() {
-// 	Global.Mutex.Lock()
-// 	defer Global.Mutex.Unlock()
-// 	Global.ReqCounter = 0
-// }
-
-// SendReqs sends a total of GlobalConfig.nreqs request to Redis for a given test
-func (c *Client) SendReqs(bench *Benchmark, reqIdChan <-chan int, wg *sync.WaitGroup,
-	pb *progressbar.ProgressBar) {
-
-	logger.Debugf("Running test %s for Client #%d", bench.BenchTestName, c.id)
-	conn := c.Pool.Get()
-	defer conn.Close()
-	defer wg.Done()
-
-	logger.Debugf("Client #%d up and runnnig", c.id)
-outer:
-	for {
-		var reqId int
-		select {
-		case reqId = <-reqIdChan:
-		case <-shutdownChan:
-			logger.Debugf("Shutdown received for client #%d", c.id)
-			break outer
-		}
-		if reqId == -1 {
-			break outer
-		}
-		logger.Debugf("Received request: %d in client #%d", reqId, c.id)
+// SendReqs sends a total of GlobalConfig.nreqs request to the store for a given test, until
+// the requests run out or the context is done. Up to Config.Pipeline requests are pipelined
+// at a time.
+func (c *Client) SendReqs(ctx context.Context, bench *Benchmark, reqIdChan <-chan int,
+	wg *sync.WaitGroup, pb *progressbar.ProgressBar) {
 
-		cmd, args, err := c.scenarios.ToRedis(bench.BenchTestName, reqId)
-		if err != nil {
-			logger.Errorf("Error in converting test to Redis format: %s", err.Error())
-			return
+	defer wg.Done()
+	c.logger.Debugf("Running test %s for Client #%d", bench.BenchTestName, c.id)
+	conn, err := c.driver.Dial()
+	if err != nil {
+		c.logger.Errorf("Client #%d cannot connect: %s", c.id, err.Error())
+		return
+	}
+	defer func() { conn.Close() }()
+
+	c.logger.Debugf("Client #%d up and runnnig", c.id)
+	for done := false; !done; {
+		reqIds := make([]int, 0, c.pipeline)
+		cmds := make([]*Command, 0, c.pipeline)
+		for len(reqIds) < c.pipeline {
+			var reqId int
+			select {
+			case reqId = <-reqIdChan:
+			case <-ctx.Done():
+				c.logger.Debugf("Shutdown received for client #%d", c.id)
+				reqId = -1
+			}
+			if reqId == -1 {
+				done = true
+				break
+			}
+			c.logger.Debugf("Received request: %d in client #%d", reqId, c.id)
+
+			cmd, args, err := c.scenarios.ToRedis(bench.BenchTestName, reqId)
+			if err != nil {
+				c.logger.Errorf("Error in converting test to Redis format: %s", err.Error())
+				return
+			}
+			if len(args) > 0 {
+				key := args[0].([]byte)
+				c.keyspace = append(c.keyspace, key)
+			}
+
+			intfArgs := make([]interface{}, len(args))
+			for i := range args {
+				intfArgs[i] = args[i]
+			}
+			reqIds = append(reqIds, reqId)
+			cmds = append(cmds, &Command{Name: cmd, Args: intfArgs})
 		}
-		if len(args) > 0 {
-			key := args[0].([]byte)
-			c.keyspace = append(c.keyspace, key)
+		if len(cmds) == 0 {
+			break
 		}
-
-		intfArgs := make([]interface{}, len(args))
-		for i := range args {
-			intfArgs[i] = args[i]
+		c.send(ctx, bench, conn, reqIds, cmds, pb)
+		// reconnect like an application would once the connection is broken, by a reset
+		// from the fault proxy for one
+		if conn.Err() != nil {
+			c.logger.Debugf("Client #%d reconnects after %s", c.id, conn.Err().Error())
+			conn.Close()
+			if conn, err = c.driver.Dial(); err != nil {
+				c.logger.Errorf("Client #%d cannot connect: %s", c.id, err.Error())
+				return
+			}
 		}
-		_, err = bench.Mark(c.id, reqId, func() (interface{}, error) {
-			res, err := conn.Do(cmd, intfArgs...)
-			return res, err
-		})
+	}
+}
 
-		if err != nil {
-			logger.Debugf("Could not send request #%d to redis due to %s", reqId, err.Error())
+// send sends the commands of the requests, pipelined if there are more than one
+func (c *Client) send(ctx context.Context, bench *Benchmark, conn DriverConn, reqIds []int,
+	cmds []*Command, pb *progressbar.ProgressBar) {
 
+	var replies []Reply
+	if len(cmds) == 1 {
+		_, err := bench.Mark(ctx, c.id, reqIds[0], func() (interface{}, error) {
+			return conn.Execute(cmds[0])
+		})
+		replies = []Reply{{Err: err}}
+	} else {
+		replies = bench.MarkPipeline(ctx, c.id, reqIds, func() []Reply {
+			return conn.Pipeline(cmds)
+		})
+	}
+	for i, reply := range replies {
+		reqId := reqIds[i]
+		if reply.Err != nil {
+			c.logger.Debugf("Could not send request #%d to redis due to %s", reqId,
+				reply.Err.Error())
 		}
 		if reqId%100 == 0 {
-			logger.Debugf("Client #%d has sent %d requests", c.id, reqId)
+			c.logger.Debugf("Client #%d has sent %d requests", c.id, reqId)
 			pb.Set(reqId)
 		}
 	}
-	// wg.Done()
 }
 
+// Close deletes the keys written by the client. The connections are shared by all the
+// clients, so they are closed with closeClients once all the clients are closed.
 func (c *Client) Close() {
-	cl := c.RedisClient.Pool.Get()
-	for _, key := range c.keyspace {
-		cl.Do("DEL", key)
+	if conn, err := c.driver.Dial(); err == nil {
+		for _, key := range c.keyspace {
+			conn.Execute(&Command{Name: "DEL", Args: []interface{}{key}})
+		}
+		conn.Close()
 	}
-	cl.Close()
-	c.RedisClient.Pool.Close()
 }
 
 // CreateClients creates and returns a Client object to be used for testing
-func CreateClients(conf *Config, scen *ScenarioSetup) []Client {
+func CreateClients(conf *Config, scen *ScenarioSetup, logger *Logger) []Client {
 
 	redisClient := RedisClient{
-		Pool:      createRedisPool(conf),
+		driver:    newDriver(conf),
+		pipeline:  conf.Pipeline,
 		scenarios: scen,
+		logger:    logger,
 	}
 	clients := make([]Client, 0, conf.NClients)
 	for id := 0; id < conf.NClients; id++ {
@@ -121,7 +153,6 @@ func CreateClients(conf *Config, scen *ScenarioSetup) []Client {
 
 func createRedisPool(conf *Config) *redis.Pool {
 	redisPool := redis.NewPool(func() (redis.Conn, error) {
-		address := fmt.Sprintf("%s:%d", conf.Host, conf.Port)
 		opts := make([]redis.DialOption, 0)
 		if conf.Timeout != -1 {
 			opts = append(opts, redis.DialConnectTimeout(conf.Timeout))
@@ -129,16 +160,65 @@ func createRedisPool(conf *Config) *redis.Pool {
 			opts = append(opts, redis.DialWriteTimeout(conf.Timeout))
 			opts = append(opts, redis.DialKeepAlive(conf.Timeout))
 		}
+		if len(conf.Username) > 0 {
+			opts = append(opts, redis.DialUsername(conf.Username))
+		}
 		if len(conf.Auth) > 0 {
 			opts = append(opts, redis.DialPassword(conf.Auth))
 		}
+		opts = append(opts, redis.DialUseTLS(conf.TLS), redis.DialTLSSkipVerify(conf.TLSSkipVerify))
 
+		// the url takes care of the host, port, credentials, database and TLS, which are
+		// also in the config to dial them through the fault proxy
+		if len(conf.URL) > 0 && len(conf.proxyAddr) == 0 {
+			return redis.DialURL(conf.URL, opts...)
+		}
 		if conf.Database != 0 {
 			opts = append(opts, redis.DialDatabase(conf.Database))
 		}
+		if conf.TLS && len(conf.proxyAddr) > 0 {
+			// the certificate is verified against the server rather than the proxy
+			opts = append(opts, redis.DialTLSConfig(&tls.Config{
+				ServerName:         conf.Host,
+				InsecureSkipVerify: conf.TLSSkipVerify,
+			}))
+		}
 		// Infof("Dial options: %v\n", opts)
-		conn, err := redis.Dial("tcp", address, opts...)
+		conn, err := redis.Dial("tcp", dialAddress(conf), opts...)
 		return conn, err
 	}, conf.NPool)
 	return redisPool
 }
+
+// ServerVersion returns the version of the server, or an empty string if the driver cannot
+// tell it
+func (c *Client) ServerVersion() string {
+	conn, err := c.driver.Dial()
+	if err != nil {
+		c.logger.Debugf("Cannot get server info: %s", err.Error())
+		return ""
+	}
+	defer conn.Close()
+	v, ok := conn.(versioner)
+	if !ok {
+		return ""
+	}
+	version, err := v.ServerVersion()
+	if err != nil {
+		c.logger.Debugf("Cannot get server info: %s", err.Error())
+	}
+	return version
+}
+
+func serverVersion(conn redis.Conn) (string, error) {
+	info, err := redis.String(conn.Do("INFO", "server"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		if strings.HasPrefix(line, "redis_version:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "redis_version:")), nil
+		}
+	}
+	return "", nil
+}
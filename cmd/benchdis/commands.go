@@ -0,0 +1,354 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/daichi-m/benchdis"
+	flag "github.com/spf13/pflag"
+)
+
+// command is a subcommand of benchdis with its own flags
+type command struct {
+	name  string
+	args  string
+	short string
+	run   func(fs *flag.FlagSet, args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"run", "[flags]", "Run the benchmark and report the results, the default command",
+			runMain},
+		{"compare", "[flags] old.json new.json",
+			"Compare the results saved by two runs and fail if they regress", compareMain},
+		{"cleanup", "--tag <tag> [flags]",
+			"Delete the keys left behind by a run with the tag", cleanupMain},
+		{"probe", "[flags]", "Check the connectivity, version and latency of the server",
+			probeMain},
+		{"serve", "[flags] <report or directory>...",
+			"Serve saved json or yaml reports as HTML", serveMain},
+		{"fake-server", "[flags]",
+			"Run an in memory fake redis server with injected latency and errors, for demos",
+			fakeServerMain},
+		{"help", "[command]", "Show the help of a command", helpMain},
+	}
+}
+
+// runCommand runs the subcommand named by the first argument. Without a subcommand the
+// arguments are the flags of run, as before the subcommands existed.
+func runCommand(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runMain(newFlagSet(findCommand("run")), args)
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", args[0])
+		printCommands()
+		return 2
+	}
+	return cmd.run(newFlagSet(cmd), args[1:])
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// newFlagSet creates the flag set of a command, whose usage lists the flags of the command
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: benchdis %s %s\n\n%s.\n\nFlags:\n%s", cmd.name, cmd.args,
+			cmd.short, fs.FlagUsages())
+		if fs.Lookup("host") != nil {
+			fmt.Fprintf(os.Stderr, "\nEvery flag can also be set with an environment variable "+
+				"like %s for --port.\n", benchdis.EnvName("port"))
+		}
+	}
+	return fs
+}
+
+// usageError reports an error in the flags of a command along with its usage, and returns
+// the exit code for it
+func usageError(fs *flag.FlagSet, err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+	fs.Usage()
+	return 2
+}
+
+func printCommands() {
+	fmt.Fprintf(os.Stderr, "Usage: benchdis <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun benchdis help <command> for the flags of a command. "+
+		"Flags without a command run the benchmark.\n")
+}
+
+// helpMain shows the usage of a command, or the list of commands
+func helpMain(fs *flag.FlagSet, args []string) int {
+	if len(args) == 0 {
+		printCommands()
+		return 0
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil || cmd.name == "help" {
+		printCommands()
+		return 2
+	}
+	// the usage lists the flags, which are only known once the command adds them
+	cmd.run(newFlagSet(cmd), []string{"--help"})
+	return 0
+}
+
+// compareMain runs the compare command which diffs the results saved by two runs and exits
+// with a non zero code if the new results regress beyond the allowed threshold.
+func compareMain(fs *flag.FlagSet, args []string) int {
+	maxreg := fs.String("max-regression", "10%", "Maximum regression allowed against old results")
+	metrics := fs.StringSlice("regression-metrics", []string{"qps", "p99"},
+		"Metrics which fail the comparison when they regress beyond --max-regression")
+	output := fs.StringP("output", "o", "table",
+		"Output format, one of "+strings.Join(benchdis.SupportedFormats, ", "))
+	if err := fs.Parse(args); err != nil {
+		return usageError(fs, err)
+	}
+	maxRegression, err := benchdis.ParsePercent(*maxreg)
+	if err == nil {
+		err = benchdis.ValidateRegressionMetrics(*metrics)
+	}
+	if err == nil && fs.NArg() != 2 {
+		err = errors.New("Exactly two result files are needed to compare")
+	}
+	if err != nil {
+		return usageError(fs, err)
+	}
+	old, err := benchdis.LoadResults(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+		return 2
+	}
+	new, err := benchdis.LoadResults(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err.Error())
+		return 2
+	}
+	cmp := benchdis.CompareResults(old, new, maxRegression, *metrics)
+	fmt.Println(benchdis.GetComparisonReporter(*output).ReportComparison(cmp))
+	if cmp.Regressed {
+		return 1
+	}
+	return 0
+}
+
+// cleanupMain deletes the keys written by a run with the tag, for runs which were killed
+// before they could clean up after themselves
+func cleanupMain(fs *flag.FlagSet, args []string) int {
+	tag := fs.String("tag", "", "Tag of the run whose keys are deleted")
+	dryRun := fs.Bool("dry-run", false, "List the keys instead of deleting them")
+	config, err := benchdis.ParseConnectionConfig(fs, args)
+	if err == nil && (len(*tag) == 0 || !benchdis.ValidTag(*tag)) {
+		err = errors.New("A tag with only letters, digits, - and _ is needed to clean up")
+	}
+	if err != nil {
+		return usageError(fs, err)
+	}
+	logger := benchdis.NewLogger(config, os.Stderr)
+
+	keys, err := benchdis.Cleanup(config, *tag, *dryRun)
+	if *dryRun {
+		for _, k := range keys {
+			fmt.Println(k)
+		}
+	}
+	if err != nil {
+		logger.Errorf("%s", err.Error())
+		return 1
+	}
+	if !*dryRun {
+		logger.Infof("Deleted %d keys tagged %s", len(keys), *tag)
+	}
+	return 0
+}
+
+// probeMain checks that the server can be reached, and reports its version and the latency
+// of PING
+func probeMain(fs *flag.FlagSet, args []string) int {
+	count := fs.Int("count", 10, "Number of PINGs to measure the latency with")
+	config, err := benchdis.ParseConnectionConfig(fs, args)
+	if err == nil && *count < 1 {
+		err = errors.New("At least one PING is needed to probe")
+	}
+	if err != nil {
+		return usageError(fs, err)
+	}
+
+	pr, err := benchdis.Probe(config, *count)
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	version := pr.ServerVersion
+	if len(version) == 0 {
+		version = "unknown"
+	}
+	fmt.Printf("Connected to %s, server version %s\n", pr.Target, version)
+	fmt.Printf("PING latency over %d requests: min %0.3f ms, avg %0.3f ms, max %0.3f ms\n",
+		pr.Count, pr.MinLatency, pr.AvgLatency, pr.MaxLatency)
+	return 0
+}
+
+var serveIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>benchdis reports</title>
+<style>body{font-family:sans-serif;margin:2em}td,th{padding:4px 12px;text-align:left}</style>
+</head><body><h1>benchdis reports</h1>
+<table><tr><th>Report</th><th>Modified</th></tr>
+{{range $i, $r := .}}<tr><td><a href="/report/{{$i}}">{{$r.Path}}</a></td>
+<td>{{$r.Modified.Format "2006-01-02 15:04:05"}}</td></tr>
+{{else}}<tr><td colspan="2">No reports found</td></tr>{{end}}
+</table></body></html>`))
+
+type servedReport struct {
+	Path     string
+	Modified time.Time
+}
+
+// serveMain serves the reports saved by earlier runs as HTML. Directories are listed again
+// on every request, so that new reports show up without a restart.
+func serveMain(fs *flag.FlagSet, args []string) int {
+	listen := fs.String("listen", ":8080", "Address to serve the reports on")
+	if err := fs.Parse(args); err != nil {
+		return usageError(fs, err)
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, errors.New("At least one report or directory is needed to serve"))
+	}
+	paths := fs.Args()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := serveIndex.Execute(w, findReports(paths)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/report/", func(w http.ResponseWriter, r *http.Request) {
+		reports := findReports(paths)
+		i, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/report/"))
+		if err != nil || i < 0 || i >= len(reports) {
+			http.NotFound(w, r)
+			return
+		}
+		rpt, err := benchdis.LoadReport(reports[i].Path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, benchdis.HtmlReporter{}.ReportResults(rpt))
+	})
+	fmt.Fprintf(os.Stderr, "Serving reports on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot serve reports: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// findReports lists the reports in the paths, looking for json, yaml and csv files in the
+// directories. The newest reports come first.
+func findReports(paths []string) []servedReport {
+	reports := make([]servedReport, 0)
+	add := func(path string, info os.FileInfo) {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json", ".yaml", ".yml", ".csv":
+			reports = append(reports, servedReport{Path: path, Modified: info.ModTime()})
+		}
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			add(p, info)
+			continue
+		}
+		entries, err := ioutil.ReadDir(p)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				add(filepath.Join(p, e.Name()), e)
+			}
+		}
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Modified.After(reports[j].Modified)
+	})
+	return reports
+}
+
+// fakeServerMain runs the fake server until it is interrupted
+func fakeServerMain(fs *flag.FlagSet, args []string) int {
+	listen := fs.String("listen", "localhost:6379", "Address to listen on")
+	latency := fs.Duration("latency", 0, "Latency added before every reply")
+	errorRate := fs.String("error-rate", "0%",
+		"Percentage of the commands failed with an injected error")
+	errs := fs.StringSlice("error", nil,
+		"Error replies to inject, like \"READONLY You can't write against a read only "+
+			"replica.\", picked at random for every failed command")
+	if err := fs.Parse(args); err != nil {
+		return usageError(fs, err)
+	}
+	rate, err := benchdis.ParsePercent(*errorRate)
+	if err == nil && (rate < 0 || rate > 100) {
+		err = errors.New("Error rate should be between 0% and 100%")
+	}
+	if err == nil && *latency < 0 {
+		err = errors.New("Latency cannot be negative")
+	}
+	if err != nil {
+		return usageError(fs, err)
+	}
+
+	server := benchdis.NewFakeServer()
+	server.Latency = *latency
+	server.ErrorRate = rate / 100
+	server.Errors = *errs
+	if err := server.Listen(*listen); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot listen on %s: %v\n", *listen, err.Error())
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Fake redis server listening on %s\n", server.Addr())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	<-interrupt
+	server.Close()
+	return 0
+}
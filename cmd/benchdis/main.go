@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"syscall"
+
+	"github.com/daichi-m/benchdis"
+	flag "github.com/spf13/pflag"
+)
+
+func main() {
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// runMain runs the benchmark and reports the results. It exits with a non zero code if the
+// results regress against the baseline or fail the SLOs.
+func runMain(fs *flag.FlagSet, args []string) int {
+
+	config, err := benchdis.ParseConfig(fs, args)
+	if err != nil {
+		return usageError(fs, err)
+	}
+	if config.PrintConfig {
+		out, err := benchdis.DumpConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot print config: %v\n", err.Error())
+			return 2
+		}
+		fmt.Print(out)
+		return 0
+	}
+	logger := benchdis.NewLogger(config, os.Stderr)
+	defer logger.Close()
+	for _, w := range config.Warnings() {
+		logger.Warnf("%s", w)
+	}
+	logger.Infof("Using following config for benchmark: \n %v \n", config)
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	enableCPUProfile(config, logger)
+	runner := benchdis.Runner{Config: config, Logger: logger, Progress: os.Stderr}
+	report, err := runner.Run(ctx)
+	if report == nil {
+		logger.Fatalf("%s", err.Error())
+	}
+	interrupted := err != nil
+	if interrupted {
+		logger.Errorf("Run was interrupted, reporting the results so far")
+	}
+
+	if err := benchdis.WriteReport(config, report, logger); err != nil {
+		logger.Errorf("Cannot write report: %s", err.Error())
+	}
+	for _, sink := range benchdis.GetSinks(config) {
+		if err := sink.Send(report); err != nil {
+			logger.Errorf("Cannot send results to %s: %s", sink.Name(), err.Error())
+		}
+	}
+	if len(config.TimeSeriesOut) > 0 {
+		if err := benchdis.WriteTimeSeries(config.TimeSeriesOut, report.Results); err != nil {
+			logger.Errorf("Cannot export time series: %s", err.Error())
+		}
+	}
+
+	logger.Infof("\n\nAll Done")
+	failedSLOs := benchdis.SLOFailed(report.SLOs)
+	if failedSLOs {
+		for _, sr := range report.SLOs {
+			if !sr.Passed {
+				logger.Errorf("SLO failed: %s", sr.Message)
+			}
+		}
+	}
+	regressed := report.Comparison != nil && report.Comparison.Regressed
+	if regressed {
+		logger.Errorf("Results regressed beyond %0.2f%% of the baseline", config.MaxRegression)
+	}
+	if interrupted {
+		return 2
+	}
+	if regressed || failedSLOs {
+		return 1
+	}
+	return 0
+}
+
+func enableCPUProfile(config *benchdis.Config, logger *benchdis.Logger) {
+	if config.CPUProf {
+		c, err := os.Create("cpu_profile.pprof")
+		if err != nil {
+			logger.Debugf("Cannot create CPU Profile: %s", err.Error())
+		}
+		pprof.StartCPUProfile(c)
+		defer pprof.StopCPUProfile()
+	}
+}
+
+// interruptContext returns a context which is cancelled on the first interrupt, so that the
+// results so far are reported. A second interrupt exits right away.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 10)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-interrupt
+		cancel()
+		<-interrupt
+		os.Exit(2)
+	}()
+	return ctx, cancel
+}
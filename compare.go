@@ -0,0 +1,270 @@
+package benchdis
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// MetricDelta is the change in one metric of a test between the baseline and the new run
+type MetricDelta struct {
+	Metric     string  `json:"metric" yaml:"metric"`
+	Old        float64 `json:"old" yaml:"old"`
+	New        float64 `json:"new" yaml:"new"`
+	Change     float64 `json:"change_pct" yaml:"change_pct"`
+	Gated      bool    `json:"gated" yaml:"gated"`
+	Regression bool    `json:"regression" yaml:"regression"`
+}
+
+// TestComparison is the comparison of all the metrics of one test
+type TestComparison struct {
+	Test   string         `json:"test" yaml:"test"`
+	Deltas []*MetricDelta `json:"deltas" yaml:"deltas"`
+}
+
+// Comparison is the diff between the baseline results and the new results, test by test
+type Comparison struct {
+	MaxRegression float64           `json:"max_regression_pct" yaml:"max_regression_pct"`
+	Tests         []*TestComparison `json:"tests" yaml:"tests"`
+	// Missing are the tests of the baseline which are not in the new results
+	Missing   []string `json:"missing,omitempty" yaml:"missing,omitempty"`
+	Regressed bool     `json:"regressed" yaml:"regressed"`
+}
+
+// ComparisonReporter generates the report of a Comparison to a string format
+type ComparisonReporter interface {
+	ReportComparison(c *Comparison) string
+}
+
+// CompareResults diffs the new results against the baseline for every test present in both.
+// QPS regresses when it drops by more than maxRegression percent and a latency regresses
+// when it grows by more than maxRegression percent. Only the gated metrics fail the
+// comparison, the rest are reported for information. Tests of the baseline missing from the
+// new results fail the comparison too, so that a gate cannot pass by skipping a test.
+func CompareResults(baseline, current []*BenchmarkResult, maxRegression float64,
+	gated []string) *Comparison {
+
+	cmp := Comparison{MaxRegression: maxRegression}
+	old := make(map[string]*BenchmarkResult, len(baseline))
+	for _, br := range baseline {
+		old[br.BenchTestName] = br
+	}
+	for _, br := range current {
+		ob, ok := old[br.BenchTestName]
+		if !ok {
+			continue
+		}
+		tc := TestComparison{Test: br.BenchTestName}
+		tc.add("qps", ob.QPS, br.QPS, true, maxRegression, gated)
+		tc.add("avg", ob.AvgLatency, br.AvgLatency, false, maxRegression, gated)
+		for _, k := range percentileKeys([]*BenchmarkResult{ob, br}) {
+			ov, ook := ob.Percentiles[k]
+			nv, nok := br.Percentiles[k]
+			if ook && nok {
+				tc.add(k, ov, nv, false, maxRegression, gated)
+			}
+		}
+		tc.add("max", ob.MaxLatency, br.MaxLatency, false, maxRegression, gated)
+		for _, d := range tc.Deltas {
+			cmp.Regressed = cmp.Regressed || d.Regression
+		}
+		cmp.Tests = append(cmp.Tests, &tc)
+	}
+	ran := make(map[string]bool, len(current))
+	for _, br := range current {
+		ran[br.BenchTestName] = true
+	}
+	for _, br := range baseline {
+		if !ran[br.BenchTestName] && !searchInList(br.BenchTestName, cmp.Missing) {
+			cmp.Missing = append(cmp.Missing, br.BenchTestName)
+			cmp.Regressed = true
+		}
+	}
+	return &cmp
+}
+
+func (tc *TestComparison) add(metric string, old, new float64, higherBetter bool,
+	maxRegression float64, gated []string) {
+
+	if old == 0 {
+		return
+	}
+	d := MetricDelta{
+		Metric: metric,
+		Old:    old,
+		New:    new,
+		Change: (new - old) / old * 100,
+		Gated:  searchInList(metric, gated),
+	}
+	worse := d.Change > maxRegression
+	if higherBetter {
+		worse = -d.Change > maxRegression
+	}
+	d.Regression = d.Gated && worse
+	tc.Deltas = append(tc.Deltas, &d)
+}
+
+// Delta returns the change of a metric for the test, if it was compared
+func (c *Comparison) Delta(test, metric string) (*MetricDelta, bool) {
+	if c == nil {
+		return nil, false
+	}
+	for _, tc := range c.Tests {
+		if tc.Test != test {
+			continue
+		}
+		for _, d := range tc.Deltas {
+			if d.Metric == metric {
+				return d, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// LoadResults reads the results saved by a previous run with the json or yaml output format.
+// Both the report envelope and the bare list of results saved by older versions are read.
+// CSV files are read as the csv output of benchdis or the output of redis-benchmark --csv.
+func LoadResults(path string) ([]*BenchmarkResult, error) {
+	rpt, err := LoadReport(path)
+	if err != nil {
+		return nil, err
+	}
+	return rpt.Results, nil
+}
+
+// LoadReport reads a report saved by an earlier run. Results saved without the envelope and
+// CSV files are read into a report without metadata.
+func LoadReport(path string) (*Report, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read results from %s: %s", path, err.Error())
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		brs, err := importCSV(content)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse results from %s: %s", path, err.Error())
+		}
+		return &Report{Results: brs}, nil
+	}
+	unmarshal := json.Unmarshal
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	}
+	var rpt Report
+	if err = unmarshal(content, &rpt); err == nil && rpt.Results != nil {
+		return &rpt, nil
+	}
+	var brs []*BenchmarkResult
+	if err = unmarshal(content, &brs); err != nil {
+		return nil, fmt.Errorf("Cannot parse results from %s: %s", path, err.Error())
+	}
+	return &Report{Results: brs}, nil
+}
+
+// importCSV reads the results of the csv output of benchdis, which starts with its header,
+// or else of the output of redis-benchmark --csv
+func importCSV(content []byte) ([]*BenchmarkResult, error) {
+	csvRd := csv.NewReader(bytes.NewReader(content))
+	csvRd.FieldsPerRecord = -1
+	records, err := csvRd.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "Test" ||
+		records[0][1] != "QPS" {
+		return ImportRedisBenchmarkCSV(bytes.NewReader(content))
+	}
+	header := records[0]
+	brs := make([]*BenchmarkResult, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		// the comparison against the baseline follows the results as a table of its own
+		if len(rec) != len(header) || rec[0] == "Test" {
+			break
+		}
+		br := BenchmarkResult{Percentiles: make(map[string]float64)}
+		for j, h := range header {
+			v := rec[j]
+			switch h {
+			case "Test":
+				if strings.HasSuffix(v, " (partial)") {
+					br.Partial = true
+					v = strings.TrimSuffix(v, " (partial)")
+				}
+				if idx := strings.Index(v, " "); idx > 0 {
+					v, br.Sweep = v[:idx], v[idx+1:]
+				}
+				br.BenchTestName = v
+				continue
+			case "Payload":
+				br.Payload = v
+				continue
+			case "Errors":
+				if br.ErrorCount, err = strconv.Atoi(v); err != nil {
+					return nil, fmt.Errorf("Line %d has invalid errors %s", i+2, v)
+				}
+				continue
+			}
+			var value *float64
+			switch k := strings.ToLower(h); {
+			case h == "QPS":
+				value = &br.QPS
+			case h == "Min":
+				value = &br.MinLatency
+			case h == "Avg":
+				value = &br.AvgLatency
+			case h == "Max":
+				value = &br.MaxLatency
+			case strings.HasPrefix(k, "p"):
+				if _, err := percentileValue(k); err == nil && v != "NA" {
+					f, err := strconv.ParseFloat(v, 64)
+					if err != nil {
+						return nil, fmt.Errorf("Line %d has invalid %s %s", i+2, h, v)
+					}
+					br.Percentiles[k] = f
+				}
+			}
+			if value == nil || v == "NA" {
+				continue
+			}
+			if *value, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("Line %d has invalid %s %s", i+2, h, v)
+			}
+		}
+		brs = append(brs, &br)
+	}
+	return brs, nil
+}
+
+// ValidateRegressionMetrics checks that the metrics gating a comparison are qps, avg, max or
+// a latency percentile like p99
+func ValidateRegressionMetrics(metrics []string) error {
+	for _, m := range metrics {
+		if m == "qps" || m == "avg" || m == "max" {
+			continue
+		}
+		if _, err := percentileValue(m); strings.HasPrefix(m, "p") && err == nil {
+			continue
+		}
+		return fmt.Errorf("Regression metric %s is not valid, should be qps, avg, max or a "+
+			"percentile like p99", m)
+	}
+	return nil
+}
+
+// ParsePercent parses a percentage like 10% or 2.5 into a float
+func ParsePercent(s string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid percentage", s)
+	}
+	return f, nil
+}
@@ -0,0 +1,34 @@
+package benchdis
+
+import "testing"
+
+func TestValidateConfigRejects(t *testing.T) {
+	conf := DefaultConfig()
+	if _, err := conf.validateConfig(); err != nil {
+		t.Fatalf("Valid config is rejected: %v", err)
+	}
+	tests := map[string]func(*Config){
+		"no clients":  func(c *Config) { c.NClients = 0 },
+		"no requests": func(c *Config) { c.NReqs = 0 },
+		"no tests":    func(c *Config) { c.Tests = []string{"nope"} },
+		"no pipeline": func(c *Config) { c.Pipeline = 0 },
+	}
+	for name, change := range tests {
+		conf := DefaultConfig()
+		change(&conf)
+		if _, err := conf.validateConfig(); err == nil {
+			t.Errorf("Config with %s is valid", name)
+		}
+	}
+}
+
+func TestValidateConfigDedupesTests(t *testing.T) {
+	conf := DefaultConfig()
+	conf.Tests = []string{"set", "get", "set", "nope", "get"}
+	if _, err := conf.validateConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Tests) != 2 || conf.Tests[0] != "set" || conf.Tests[1] != "get" {
+		t.Errorf("Tests = %v, want set and get once", conf.Tests)
+	}
+}
@@ -0,0 +1,83 @@
+package benchdis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultConfig is the config used when neither a config file nor a flag sets an option
+func DefaultConfig() Config {
+	return Config{
+		Driver:            "redis",
+		Host:              "localhost",
+		Port:              6379,
+		Timeout:           10 * time.Second,
+		NClients:          1,
+		NPool:             50,
+		NReqs:             100000,
+		Pipeline:          1,
+		ReqSize:           50,
+		Payload:           "random",
+		Tests:             append([]string{}, SupportedTests...),
+		Repeat:            1,
+		CleanupTimeout:    30 * time.Second,
+		OutputFormat:      "table",
+		Sort:              "tests",
+		QPS:               true,
+		Latency:           true,
+		Percentiles:       []float64{50, 75, 90, 99},
+		Interval:          time.Second,
+		MaxRegression:     10,
+		RegressionMetrics: []string{"qps", "p99"},
+	}
+}
+
+// configFileArg finds the value of the --config flag in the command line arguments, or else
+// in its environment variable, before the rest of the flags are parsed
+func configFileArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "--config=") {
+			return strings.TrimPrefix(arg, "--config=")
+		}
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return os.Getenv(EnvName("config"))
+}
+
+// loadConfigFile reads a yaml or json config file on top of conf. The keys are the same as
+// those of the config in the saved reports, options missing in the file are left as they are.
+// An auth of the form $VAR or ${VAR} is read from the environment variable.
+func loadConfigFile(path string, conf *Config) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Cannot read config from %s: %s", path, err.Error())
+	}
+	// json is a subset of yaml, so the yaml decoder reads both, along with the durations
+	if err := yaml.UnmarshalStrict(content, conf); err != nil {
+		return fmt.Errorf("Cannot parse config from %s: %s", path, err.Error())
+	}
+	if strings.HasPrefix(conf.Auth, "$") {
+		conf.Auth = os.ExpandEnv(conf.Auth)
+	}
+	return nil
+}
+
+// DumpConfig renders the config as yaml that can be given back to --config, with the auth
+// redacted
+func DumpConfig(conf *Config) (string, error) {
+	out, err := yaml.Marshal(conf.Redacted())
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,122 @@
+package benchdis
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// setEnv sets the environment variables for the rest of the test
+func setEnv(t *testing.T, vars map[string]string) {
+	t.Helper()
+	for k, v := range vars {
+		old, had := os.LookupEnv(k)
+		os.Setenv(k, v)
+		k := k
+		t.Cleanup(func() {
+			if had {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+// writeFile writes the content to a file in the temporary directory of the test
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	path := writeFile(t, "bench.yaml", "clients: 5\nrequests: 300\ntimeout: 2s\n"+
+		"tests: [get]\n")
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		clients  int
+		requests int
+	}{
+		{"file", nil, []string{"--config", path}, 5, 300},
+		{"file from the environment", map[string]string{EnvName("config"): path}, nil, 5, 300},
+		{"environment over file", map[string]string{EnvName("clients"): "7"},
+			[]string{"--config=" + path}, 7, 300},
+		{"flag over environment", map[string]string{EnvName("clients"): "7"},
+			[]string{"--config", path, "-c", "9"}, 9, 300},
+		{"flag over file", nil, []string{"--config", path, "--requests", "400"}, 5, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			conf, err := ParseConfig(flag.NewFlagSet("run", flag.ContinueOnError), tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.NClients != tt.clients || conf.NReqs != tt.requests {
+				t.Errorf("Config has %d clients and %d requests, want %d and %d",
+					conf.NClients, conf.NReqs, tt.clients, tt.requests)
+			}
+			// the options which are only in the file are kept
+			if conf.Timeout != 2*time.Second || len(conf.Tests) != 1 || conf.Tests[0] != "get" {
+				t.Errorf("Config has timeout %v and tests %v from the file", conf.Timeout,
+					conf.Tests)
+			}
+		})
+	}
+
+	bad := writeFile(t, "bad.yaml", "clients: 5\nthreads: 4\n")
+	_, err := ParseConfig(flag.NewFlagSet("run", flag.ContinueOnError), []string{"--config", bad})
+	if err == nil {
+		t.Error("Config file with an unknown key did not fail")
+	}
+}
+
+func TestDumpConfigRedactsAuth(t *testing.T) {
+	const password = "s3cret-pass"
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{"flag", nil, []string{"--auth", password}},
+		{"auth file", nil, []string{"--auth-file", writeFile(t, "auth", password+"\n")}},
+		{"environment", map[string]string{EnvName("auth"): password}, nil},
+		{"redis-cli environment", map[string]string{"REDISCLI_AUTH": password}, nil},
+		{"config file", map[string]string{"BENCH_PASS": password},
+			[]string{"--config", writeFile(t, "bench.yaml", "auth: ${BENCH_PASS}\n")}},
+		{"url", nil, []string{"--url", "redis://bench:" + password + "@cache:6380/1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			conf, err := ParseConfig(flag.NewFlagSet("run", flag.ContinueOnError), tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.Auth != password {
+				t.Errorf("Config has auth %q, want the password", conf.Auth)
+			}
+			out, err := DumpConfig(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(out, password) {
+				t.Errorf("Dumped config has the password:\n%s", out)
+			}
+			if conf.Auth != password {
+				t.Errorf("Dumping the config changed its auth to %q", conf.Auth)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package benchdis
+
+import (
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// SupportedDrivers are the stores that can be benchmarked. redis also covers the RESP
+// compatible stores like KeyDB and Dragonfly.
+var SupportedDrivers []string = []string{"redis", "memcached"}
+
+// Command is a request sent through a driver, named and laid out as a redis command
+type Command struct {
+	Name string
+	Args []interface{}
+}
+
+// Reply is the reply to one command of a pipeline
+type Reply struct {
+	Value interface{}
+	Err   error
+}
+
+// Driver connects the clients to the store being benchmarked. The connections are shared by
+// all the clients, so a driver should pool them.
+type Driver interface {
+	// Dial returns a connection to the store, which is given back by closing it
+	Dial() (DriverConn, error)
+	// Close closes all the connections of the driver
+	Close() error
+}
+
+// DriverConn is a connection of a driver
+type DriverConn interface {
+	// Execute sends a command and waits for its reply
+	Execute(cmd *Command) (interface{}, error)
+	// Pipeline sends all the commands before reading any reply, returning the replies in the
+	// order of the commands
+	Pipeline(cmds []*Command) []Reply
+	// Err tells if the connection is broken and has to be dialed again
+	Err() error
+	Close() error
+}
+
+// versioner is implemented by the connections which can tell the version of the server
+type versioner interface {
+	ServerVersion() (string, error)
+}
+
+// newDriver creates the driver of the config
+func newDriver(conf *Config) Driver {
+	switch conf.Driver {
+	case "memcached":
+		return NewMemcachedDriver(conf)
+	default:
+		return NewRedisDriver(conf)
+	}
+}
+
+// driverTests are the tests supported by the drivers other than redis
+var driverTests = map[string][]string{
+	"memcached": memcachedTests,
+}
+
+// driverDefaultTests narrows the default tests, which are all the supported tests, to the
+// tests the driver can run
+func driverDefaultTests(driver string, tests []string) []string {
+	supported, ok := driverTests[driver]
+	if !ok || len(tests) != len(SupportedTests) {
+		return tests
+	}
+	for i := range tests {
+		if tests[i] != SupportedTests[i] {
+			return tests
+		}
+	}
+	return append([]string{}, supported...)
+}
+
+// validateDriver checks that the driver is supported and can run all the tests
+func validateDriver(driver string, tests []string) error {
+	if !searchInList(driver, SupportedDrivers) {
+		return fmt.Errorf("Driver %s is not valid, should be one of %v", driver, SupportedDrivers)
+	}
+	supported, ok := driverTests[driver]
+	if !ok {
+		return nil
+	}
+	for _, t := range tests {
+		if !searchInList(t, supported) {
+			return fmt.Errorf("Test %s is not supported by the %s driver, which supports %v",
+				t, driver, supported)
+		}
+	}
+	return nil
+}
+
+// RedisDriver is the default driver, which talks to redis and RESP compatible stores
+// through a redigo pool
+type RedisDriver struct {
+	Pool *redis.Pool
+}
+
+var _ Driver = RedisDriver{}
+
+// NewRedisDriver creates the redis driver with a pool of Config.NPool connections
+func NewRedisDriver(conf *Config) RedisDriver {
+	return RedisDriver{Pool: createRedisPool(conf)}
+}
+
+// Dial gets a connection from the pool. A failure to connect is returned by the commands sent
+// on the connection, so that it is counted against the requests.
+func (rd RedisDriver) Dial() (DriverConn, error) {
+	return redisConn{rd.Pool.Get()}, nil
+}
+
+func (rd RedisDriver) Close() error {
+	return rd.Pool.Close()
+}
+
+type redisConn struct {
+	redis.Conn
+}
+
+func (rc redisConn) Execute(cmd *Command) (interface{}, error) {
+	return rc.Do(cmd.Name, cmd.Args...)
+}
+
+func (rc redisConn) Pipeline(cmds []*Command) []Reply {
+	replies := make([]Reply, len(cmds))
+	for _, cmd := range cmds {
+		if err := rc.Send(cmd.Name, cmd.Args...); err != nil {
+			return failedReplies(replies, err)
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return failedReplies(replies, err)
+	}
+	for i := range cmds {
+		replies[i].Value, replies[i].Err = rc.Receive()
+	}
+	return replies
+}
+
+func (rc redisConn) ServerVersion() (string, error) {
+	return serverVersion(rc.Conn)
+}
+
+// failedReplies fails all the replies of a pipeline with the error
+func failedReplies(replies []Reply, err error) []Reply {
+	for i := range replies {
+		replies[i] = Reply{Err: err}
+	}
+	return replies
+}
@@ -0,0 +1,58 @@
+package benchdis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// EnvPrefix is the prefix of the environment variables which set the flags, like
+// BENCHDIS_CLIENTS for --clients
+const EnvPrefix = "BENCHDIS_"
+
+// EnvName is the environment variable for a flag
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag which is not set on the command line from its environment
+// variable, if that is set
+func applyEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Changed || err != nil {
+			return
+		}
+		v, ok := os.LookupEnv(EnvName(f.Name))
+		if !ok {
+			return
+		}
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("Invalid value %q in %s: %s", v, EnvName(f.Name), e.Error())
+		}
+	})
+	return err
+}
+
+// resolveAuth picks the password for redis auth. The --auth flag or BENCHDIS_AUTH is used if
+// set, then the auth file, then the auth in the config file and at last REDISCLI_AUTH as
+// redis-cli does.
+func resolveAuth(explicit, fromFile, authFile string) (string, error) {
+	if len(explicit) > 0 {
+		return explicit, nil
+	}
+	if len(authFile) > 0 {
+		content, err := ioutil.ReadFile(authFile)
+		if err != nil {
+			return "", fmt.Errorf("Cannot read auth from %s: %s", authFile, err.Error())
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	if len(fromFile) > 0 {
+		return fromFile, nil
+	}
+	return os.Getenv("REDISCLI_AUTH"), nil
+}
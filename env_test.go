@@ -0,0 +1,144 @@
+package benchdis
+
+import (
+	"fmt"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+)
+
+func TestEnvSetsFlags(t *testing.T) {
+	sloEnv := writeFile(t, "env.yaml", "- get.p99 < 2ms\n")
+	sloArg := writeFile(t, "arg.yaml", "- set.p99 < 2ms\n")
+	authEnv := writeFile(t, "env.auth", "from-env\n")
+	authArg := writeFile(t, "arg.auth", "from-arg\n")
+	target := func(c *Config) interface{} {
+		return fmt.Sprintf("%s:%d/%d", c.Host, c.Port, c.Database)
+	}
+
+	// every flag is set from its environment variable, and then overridden on the command line
+	tests := []struct {
+		flag             string
+		env, arg         string
+		value            func(c *Config) interface{}
+		fromEnv, fromArg string
+	}{
+		{"driver", "memcached", "redis", func(c *Config) interface{} { return c.Driver },
+			"memcached", "redis"},
+		{"url", "redis://cache:6380/2", "redis://other:6381/3", target,
+			"cache:6380/2", "other:6381/3"},
+		{"host", "cache", "other", target, "cache:6379/0", "other:6379/0"},
+		{"port", "6380", "6381", target, "localhost:6380/0", "localhost:6381/0"},
+		{"db", "2", "3", target, "localhost:6379/2", "localhost:6379/3"},
+		{"user", "bench", "admin", func(c *Config) interface{} { return c.Username },
+			"bench", "admin"},
+		{"auth", "env-pass", "arg-pass", func(c *Config) interface{} { return c.Auth },
+			"env-pass", "arg-pass"},
+		{"auth-file", authEnv, authArg, func(c *Config) interface{} { return c.Auth },
+			"from-env", "from-arg"},
+		{"tls", "true", "false", func(c *Config) interface{} { return c.TLS }, "true", "false"},
+		{"tls-skip-verify", "true", "false",
+			func(c *Config) interface{} { return c.TLSSkipVerify }, "true", "false"},
+		{"timeout", "2s", "3s", func(c *Config) interface{} { return c.Timeout }, "2s", "3s"},
+		{"quiet", "true", "false", func(c *Config) interface{} { return c.Quiet },
+			"true", "false"},
+		{"debug", "true", "false", func(c *Config) interface{} { return c.Debug },
+			"true", "false"},
+		{"print-config", "true", "false", func(c *Config) interface{} { return c.PrintConfig },
+			"true", "false"},
+		{"clients", "7", "9", func(c *Config) interface{} { return c.NClients }, "7", "9"},
+		{"pool", "100", "200", func(c *Config) interface{} { return c.NPool }, "100", "200"},
+		{"requests", "300", "400", func(c *Config) interface{} { return c.NReqs }, "300", "400"},
+		{"pipeline", "2", "3", func(c *Config) interface{} { return c.Pipeline }, "2", "3"},
+		{"data", "16", "32", func(c *Config) interface{} { return c.ReqSize }, "16", "32"},
+		{"payload", "zeros", "text", func(c *Config) interface{} { return c.Payload },
+			"zeros", "text"},
+		{"tests", "get,set", "incr", func(c *Config) interface{} { return c.Tests },
+			"[get set]", "[incr]"},
+		{"tag", "env", "arg", func(c *Config) interface{} { return c.Tag }, "env", "arg"},
+		{"repeat", "2", "3", func(c *Config) interface{} { return c.Repeat }, "2", "3"},
+		{"cooldown", "1s", "2s", func(c *Config) interface{} { return c.Cooldown }, "1s", "2s"},
+		{"cleanup-timeout", "5s", "6s", func(c *Config) interface{} { return c.CleanupTimeout },
+			"5s", "6s"},
+		{"baseline", "env.json", "arg.json", func(c *Config) interface{} { return c.Baseline },
+			"env.json", "arg.json"},
+		{"max-regression", "5%", "20%", func(c *Config) interface{} { return c.MaxRegression },
+			"5", "20"},
+		{"regression-metrics", "qps", "qps,p99",
+			func(c *Config) interface{} { return c.RegressionMetrics }, "[qps]", "[qps p99]"},
+		{"slo", sloEnv, sloArg, func(c *Config) interface{} { return c.SLOFile }, sloEnv,
+			sloArg},
+		{"fault", "0s-1s:latency=5ms", "1s-2s:drop=1%",
+			func(c *Config) interface{} { return c.Faults }, "[0s-1s:latency=5ms]",
+			"[1s-2s:drop=1%]"},
+		{"sweep", "clients=1,2", "clients=2,4", func(c *Config) interface{} { return c.Sweep },
+			"clients=1,2", "clients=2,4"},
+		{"qps", "false", "true", func(c *Config) interface{} { return c.QPS }, "false", "true"},
+		{"latency", "false", "true", func(c *Config) interface{} { return c.Latency },
+			"false", "true"},
+		{"percentiles", "50,99", "90", func(c *Config) interface{} { return c.Percentiles },
+			"[50 99]", "[90]"},
+		{"interval", "2s", "0s", func(c *Config) interface{} { return c.Interval }, "2s", "0s"},
+		{"timeseries", "ts.csv", "ts.json",
+			func(c *Config) interface{} { return c.TimeSeriesOut }, "ts.csv", "ts.json"},
+		{"output", "json", "yaml", func(c *Config) interface{} { return c.OutputFormat },
+			"json", "yaml"},
+		{"out", "env.txt", "arg.txt", func(c *Config) interface{} { return c.Out },
+			"env.txt", "arg.txt"},
+		{"sort", "qps", "name", func(c *Config) interface{} { return c.Sort }, "qps", "name"},
+		{"prometheus-listen", ":9121", ":9122",
+			func(c *Config) interface{} { return c.PromListen }, ":9121", ":9122"},
+		{"pushgateway", "http://env", "http://arg",
+			func(c *Config) interface{} { return c.Pushgateway }, "http://env", "http://arg"},
+		{"influx", "env.lp", "arg.lp", func(c *Config) interface{} { return c.Influx },
+			"env.lp", "arg.lp"},
+		{"webhook", "http://env", "http://arg", func(c *Config) interface{} { return c.Webhook },
+			"http://env", "http://arg"},
+		{"report-config", "true", "false",
+			func(c *Config) interface{} { return c.ReportConfig }, "true", "false"},
+		{"cpu", "true", "false", func(c *Config) interface{} { return c.CPUProf },
+			"true", "false"},
+		{"mem", "true", "false", func(c *Config) interface{} { return c.MemProf },
+			"true", "false"},
+	}
+
+	covered := map[string]bool{"config": true}
+	for _, tt := range tests {
+		covered[tt.flag] = true
+		t.Run(tt.flag, func(t *testing.T) {
+			setEnv(t, map[string]string{EnvName(tt.flag): tt.env})
+			conf, err := ParseConfig(flag.NewFlagSet("run", flag.ContinueOnError), nil)
+			if err != nil {
+				t.Fatalf("%s=%s failed: %v", EnvName(tt.flag), tt.env, err)
+			}
+			if got := fmt.Sprint(tt.value(conf)); got != tt.fromEnv {
+				t.Errorf("%s=%s sets %s, want %s", EnvName(tt.flag), tt.env, got, tt.fromEnv)
+			}
+
+			conf, err = ParseConfig(flag.NewFlagSet("run", flag.ContinueOnError),
+				[]string{"--" + tt.flag + "=" + tt.arg})
+			if err != nil {
+				t.Fatalf("--%s=%s failed: %v", tt.flag, tt.arg, err)
+			}
+			if got := fmt.Sprint(tt.value(conf)); got != tt.fromArg {
+				t.Errorf("--%s=%s over the environment sets %s, want %s", tt.flag, tt.arg, got,
+					tt.fromArg)
+			}
+		})
+	}
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	if _, err := ParseConfig(fs, nil); err != nil {
+		t.Fatal(err)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if !covered[f.Name] {
+			t.Errorf("Environment variable %s is not tested", EnvName(f.Name))
+		}
+	})
+
+	setEnv(t, map[string]string{EnvName("clients"): "many"})
+	if _, err := ParseConfig(flag.NewFlagSet("run", flag.ContinueOnError), nil); err == nil {
+		t.Errorf("Invalid %s did not fail", EnvName("clients"))
+	}
+}
@@ -0,0 +1,110 @@
+package benchdis
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const maxErrorSampleLen = 200
+
+// ErrorStat is the number of errors of one category seen in a benchmark test along with a
+// sample error message to diagnose the failure
+type ErrorStat struct {
+	Count  int    `json:"count" yaml:"count"`
+	Sample string `json:"sample" yaml:"sample"`
+}
+
+// redisErrorPrefixes maps the error prefixes sent by the redis server to error categories
+var redisErrorPrefixes = map[string]string{
+	"OOM":         "oom",
+	"READONLY":    "readonly",
+	"MOVED":       "moved",
+	"ASK":         "ask",
+	"LOADING":     "loading",
+	"BUSY":        "busy",
+	"NOAUTH":      "noauth",
+	"WRONGPASS":   "noauth",
+	"WRONGTYPE":   "wrongtype",
+	"CLUSTERDOWN": "clusterdown",
+	"TRYAGAIN":    "tryagain",
+}
+
+// classifyError maps an error received while benchmarking into a category like timeout,
+// conn_reset or one of the redis error replies like oom or readonly.
+func classifyError(err error) string {
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		prefix := strings.SplitN(string(redisErr), " ", 2)[0]
+		if cat, ok := redisErrorPrefixes[prefix]; ok {
+			return cat
+		}
+		return "server"
+	}
+	var mcErr MemcachedError
+	if errors.As(err, &mcErr) {
+		switch {
+		case strings.HasPrefix(string(mcErr), "SERVER_ERROR out of memory"):
+			return "oom"
+		case strings.HasPrefix(string(mcErr), "CLIENT_ERROR"):
+			return "client_error"
+		}
+		return "server"
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "conn_reset"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "conn_refused"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, net.ErrClosed):
+		return "conn_closed"
+	case errors.Is(err, redis.ErrPoolExhausted):
+		return "pool_exhausted"
+	}
+	return "other"
+}
+
+// markError records an error against its category, keeping the first error message of
+// each category as the sample
+func (b *Benchmark) markError(err error) {
+	cat := classifyError(err)
+	if b.promMetrics != nil {
+		b.promMetrics.observeError(cat)
+	}
+	b.errMutex.Lock()
+	defer b.errMutex.Unlock()
+	es, ok := b.errorStats[cat]
+	if !ok {
+		msg := err.Error()
+		if len(msg) > maxErrorSampleLen {
+			msg = msg[:maxErrorSampleLen]
+		}
+		es = &ErrorStat{Sample: msg}
+		b.errorStats[cat] = es
+	}
+	es.Count++
+}
+
+// errorCategories returns the error categories of a result sorted by descending count
+func errorCategories(br *BenchmarkResult) []string {
+	cats := make([]string, 0, len(br.Errors))
+	for c := range br.Errors {
+		cats = append(cats, c)
+	}
+	sort.Slice(cats, func(i, j int) bool {
+		ci, cj := br.Errors[cats[i]].Count, br.Errors[cats[j]].Count
+		if ci == cj {
+			return cats[i] < cats[j]
+		}
+		return ci > cj
+	})
+	return cats
+}
@@ -0,0 +1,449 @@
+package benchdis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeServer is a minimal in memory server speaking RESP. It supports the commands of the
+// SupportedTests along with the ones benchdis sends around them, like INFO, DEL and SCAN, so
+// that benchdis can be tested and demoed without a redis server. Latency and errors can be
+// injected into its replies, and are set before the server starts listening.
+type FakeServer struct {
+	// Latency is added before every reply
+	Latency time.Duration
+	// ErrorRate is the fraction of the commands, between 0 and 1, failed with an injected error
+	ErrorRate float64
+	// Errors are the injected error replies, like "READONLY You can't write against a read
+	// only replica.", one of which is picked at random for every failed command
+	Errors []string
+
+	listener net.Listener
+	data     map[string]interface{}
+	rand     *rand.Rand
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+}
+
+// FakeServerVersion is the redis version reported by the fake server
+const FakeServerVersion = "7.0.0-benchdis"
+
+const defaultInjectedError = "ERR injected error"
+
+// NewFakeServer creates a fake server with no latency or errors injected
+func NewFakeServer() *FakeServer {
+	return &FakeServer{
+		data:  make(map[string]interface{}),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen starts serving on the address, like localhost:6379 or localhost:0 for any free port,
+// until the server is closed
+func (fs *FakeServer) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fs.listener = listener
+	fs.wg.Add(1)
+	go fs.accept()
+	return nil
+}
+
+// Addr is the address the server listens on
+func (fs *FakeServer) Addr() string {
+	return fs.listener.Addr().String()
+}
+
+// Close stops the server and closes all its connections
+func (fs *FakeServer) Close() error {
+	fs.mutex.Lock()
+	fs.closed = true
+	err := fs.listener.Close()
+	for conn := range fs.conns {
+		conn.Close()
+	}
+	fs.mutex.Unlock()
+	fs.wg.Wait()
+	return err
+}
+
+// Keys returns the number of keys stored in the server
+func (fs *FakeServer) Keys() int {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return len(fs.data)
+}
+
+func (fs *FakeServer) accept() {
+	defer fs.wg.Done()
+	for {
+		conn, err := fs.listener.Accept()
+		if err != nil {
+			return
+		}
+		fs.mutex.Lock()
+		if fs.closed {
+			fs.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		fs.conns[conn] = struct{}{}
+		fs.wg.Add(1)
+		fs.mutex.Unlock()
+		go fs.serve(conn)
+	}
+}
+
+func (fs *FakeServer) serve(conn net.Conn) {
+	defer fs.wg.Done()
+	defer func() {
+		fs.mutex.Lock()
+		delete(fs.conns, conn)
+		fs.mutex.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if fs.Latency > 0 {
+			time.Sleep(fs.Latency)
+		}
+		reply := fs.execute(args)
+		writeRESP(w, reply)
+		// flush once the pipelined commands read so far are all answered
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// fakeArity is the least number of arguments of the commands of the fake server
+var fakeArity = map[string]int{
+	"GET": 1, "SET": 2, "INCR": 1, "LPUSH": 2, "RPUSH": 2, "LPOP": 1, "RPOP": 1, "SADD": 2,
+	"SPOP": 1, "HSET": 3, "HGET": 2, "DEL": 1, "SCAN": 1, "AUTH": 1, "SELECT": 1,
+}
+
+// respError is an error reply of the fake server
+type respError string
+
+// execute runs a command and returns its reply
+func (fs *FakeServer) execute(args [][]byte) interface{} {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if fs.ErrorRate > 0 && fs.rand.Float64() < fs.ErrorRate {
+		if len(fs.Errors) == 0 {
+			return respError(defaultInjectedError)
+		}
+		return respError(fs.Errors[fs.rand.Intn(len(fs.Errors))])
+	}
+
+	cmd := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	if n, ok := fakeArity[cmd]; ok && len(args) < n {
+		return respError(fmt.Sprintf("ERR wrong number of arguments for '%s' command",
+			strings.ToLower(cmd)))
+	}
+
+	switch cmd {
+	case "PING":
+		if len(args) > 0 {
+			return args[0]
+		}
+		return "PONG"
+	case "ECHO":
+		if len(args) > 0 {
+			return args[0]
+		}
+		return nil
+	case "AUTH", "SELECT":
+		return "OK"
+	case "INFO":
+		return []byte("# Server\r\nredis_version:" + FakeServerVersion + "\r\n")
+	case "DBSIZE":
+		return int64(len(fs.data))
+	case "FLUSHDB", "FLUSHALL":
+		fs.data = make(map[string]interface{})
+		return "OK"
+	case "SET":
+		fs.data[string(args[0])] = append([]byte{}, args[1]...)
+		return "OK"
+	case "GET":
+		switch v := fs.data[string(args[0])].(type) {
+		case nil:
+			return nil
+		case []byte:
+			return v
+		}
+		return wrongType
+	case "INCR":
+		var n int64
+		switch v := fs.data[string(args[0])].(type) {
+		case nil:
+		case []byte:
+			var err error
+			if n, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+				return respError("ERR value is not an integer or out of range")
+			}
+		default:
+			return wrongType
+		}
+		n++
+		fs.data[string(args[0])] = []byte(strconv.FormatInt(n, 10))
+		return n
+	case "LPUSH", "RPUSH":
+		list, ok := fs.value(args[0], &fakeList{}).(*fakeList)
+		if !ok {
+			return wrongType
+		}
+		for _, v := range args[1:] {
+			list.push(cmd == "LPUSH", append([]byte{}, v...))
+		}
+		fs.data[string(args[0])] = list
+		return int64(list.len())
+	case "LPOP", "RPOP":
+		list, ok := fs.value(args[0], &fakeList{}).(*fakeList)
+		if !ok {
+			return wrongType
+		}
+		if list.len() == 0 {
+			return nil
+		}
+		v := list.pop(cmd == "LPOP")
+		fs.store(args[0], list, list.len())
+		return v
+	case "SADD":
+		set, ok := fs.value(args[0], map[string]struct{}{}).(map[string]struct{})
+		if !ok {
+			return wrongType
+		}
+		added := int64(0)
+		for _, m := range args[1:] {
+			if _, ok := set[string(m)]; !ok {
+				set[string(m)] = struct{}{}
+				added++
+			}
+		}
+		fs.data[string(args[0])] = set
+		return added
+	case "SPOP":
+		set, ok := fs.value(args[0], map[string]struct{}{}).(map[string]struct{})
+		if !ok {
+			return wrongType
+		}
+		for m := range set {
+			delete(set, m)
+			fs.store(args[0], set, len(set))
+			return []byte(m)
+		}
+		return nil
+	case "HSET":
+		hash, ok := fs.value(args[0], map[string][]byte{}).(map[string][]byte)
+		if !ok {
+			return wrongType
+		}
+		added := int64(0)
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, ok := hash[string(args[i])]; !ok {
+				added++
+			}
+			hash[string(args[i])] = append([]byte{}, args[i+1]...)
+		}
+		fs.data[string(args[0])] = hash
+		return added
+	case "HGET":
+		hash, ok := fs.value(args[0], map[string][]byte{}).(map[string][]byte)
+		if !ok {
+			return wrongType
+		}
+		if v, ok := hash[string(args[1])]; ok {
+			return v
+		}
+		return nil
+	case "DEL":
+		deleted := int64(0)
+		for _, k := range args {
+			if _, ok := fs.data[string(k)]; ok {
+				delete(fs.data, string(k))
+				deleted++
+			}
+		}
+		return deleted
+	case "SCAN":
+		// every key is returned in one go, so the cursor is always 0
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(string(args[i])) == "MATCH" {
+				pattern = string(args[i+1])
+			}
+		}
+		keys := make([]interface{}, 0)
+		for k := range fs.data {
+			if ok, _ := path.Match(pattern, k); ok {
+				keys = append(keys, []byte(k))
+			}
+		}
+		return []interface{}{[]byte("0"), keys}
+	}
+	return respError(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(cmd)))
+}
+
+var wrongType = respError("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// value returns the value of the key, or empty if the key does not exist
+func (fs *FakeServer) value(key []byte, empty interface{}) interface{} {
+	if v, ok := fs.data[string(key)]; ok {
+		return v
+	}
+	return empty
+}
+
+// store stores the value of a collection, removing the key once it is empty as redis does
+func (fs *FakeServer) store(key []byte, v interface{}, size int) {
+	if size == 0 {
+		delete(fs.data, string(key))
+		return
+	}
+	fs.data[string(key)] = v
+}
+
+// fakeList is a list which pushes and pops at both ends in constant time. The head holds
+// the elements pushed to the left in reverse, so that both ends are appended to.
+type fakeList struct {
+	head [][]byte
+	tail [][]byte
+}
+
+func (l *fakeList) len() int {
+	return len(l.head) + len(l.tail)
+}
+
+func (l *fakeList) push(left bool, v []byte) {
+	if left {
+		l.head = append(l.head, v)
+	} else {
+		l.tail = append(l.tail, v)
+	}
+}
+
+// pop removes an element from the left or the right of a list which is not empty
+func (l *fakeList) pop(left bool) []byte {
+	var v []byte
+	switch {
+	case left && len(l.head) > 0:
+		v, l.head = l.head[len(l.head)-1], l.head[:len(l.head)-1]
+	case left:
+		v, l.tail = l.tail[0], l.tail[1:]
+	case len(l.tail) > 0:
+		v, l.tail = l.tail[len(l.tail)-1], l.tail[:len(l.tail)-1]
+	default:
+		v, l.head = l.head[0], l.head[1:]
+	}
+	return v
+}
+
+// maxRESPArgs and maxRESPBulk are the limits of redis on the number of arguments of a
+// command and the size of an argument, the proto-max-bulk-len of 512MB, which are checked
+// before anything is allocated for the command
+const (
+	maxRESPArgs = 1024 * 1024
+	maxRESPBulk = 512 * 1024 * 1024
+)
+
+// readRESPCommand reads a command sent as a RESP array of bulk strings, or inline as sent by
+// telnet
+func readRESPCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		fields := strings.Fields(string(line))
+		args := make([][]byte, len(fields))
+		for i, f := range fields {
+			args[i] = []byte(f)
+		}
+		return args, nil
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 || n > maxRESPArgs {
+		return nil, fmt.Errorf("Malformed command length %q", line)
+	}
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readRESPLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("Malformed command argument %q", line)
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxRESPBulk {
+			return nil, fmt.Errorf("Malformed command argument %q", line)
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+func readRESPLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("Malformed line, should end with CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+// writeRESP writes a reply, which is a simple string, an error, an integer, a bulk string, an
+// array or nil
+func writeRESP(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case string:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case respError:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n", len(v))
+		w.Write(v)
+		w.WriteString("\r\n")
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, e := range v {
+			writeRESP(w, e)
+		}
+	}
+}
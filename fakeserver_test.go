@@ -0,0 +1,201 @@
+package benchdis
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// startFakeServer starts a fake server on a free port, which is closed with the test
+func startFakeServer(t *testing.T) *FakeServer {
+	t.Helper()
+	server := NewFakeServer()
+	if err := server.Listen("localhost:0"); err != nil {
+		t.Fatalf("Cannot start fake server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+// testConfig is a small benchmark config against the server
+func testConfig(t *testing.T, server *FakeServer) *Config {
+	t.Helper()
+	return addrConfig(t, server.Addr())
+}
+
+// addrConfig is a small benchmark config against the server at the address
+func addrConfig(t *testing.T, addr string) *Config {
+	t.Helper()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := DefaultConfig()
+	conf.Host = host
+	conf.Port, _ = strconv.Atoi(port)
+	conf.Timeout = 5 * time.Second
+	conf.NClients = 4
+	conf.NPool = 10
+	conf.NReqs = 200
+	conf.Interval = 0
+	return &conf
+}
+
+func dialFake(t *testing.T, server *FakeServer) redis.Conn {
+	t.Helper()
+	conn, err := redis.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("Cannot connect to fake server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestFakeServerCommands(t *testing.T) {
+	conn := dialFake(t, startFakeServer(t))
+	tests := []struct {
+		cmd  string
+		args []interface{}
+		want interface{}
+	}{
+		{"PING", nil, "PONG"},
+		{"GET", []interface{}{"k"}, nil},
+		{"SET", []interface{}{"k", "v"}, "OK"},
+		{"GET", []interface{}{"k"}, []byte("v")},
+		{"INCR", []interface{}{"n"}, int64(1)},
+		{"INCR", []interface{}{"n"}, int64(2)},
+		{"INCR", []interface{}{"k"}, redis.Error("ERR value is not an integer or out of range")},
+		{"LPUSH", []interface{}{"l", "a"}, int64(1)},
+		{"RPUSH", []interface{}{"l", "b"}, int64(2)},
+		{"LPUSH", []interface{}{"l", "c"}, int64(3)},
+		{"RPOP", []interface{}{"l"}, []byte("b")},
+		{"LPOP", []interface{}{"l"}, []byte("c")},
+		{"LPOP", []interface{}{"l"}, []byte("a")},
+		{"LPOP", []interface{}{"l"}, nil},
+		{"SADD", []interface{}{"s", "a"}, int64(1)},
+		{"SADD", []interface{}{"s", "a"}, int64(0)},
+		{"SPOP", []interface{}{"s"}, []byte("a")},
+		{"SPOP", []interface{}{"s"}, nil},
+		{"HSET", []interface{}{"h", "f", "v"}, int64(1)},
+		{"HGET", []interface{}{"h", "f"}, []byte("v")},
+		{"HGET", []interface{}{"h", "g"}, nil},
+		{"HGET", []interface{}{"k", "f"}, redis.Error(wrongType)},
+		{"DEL", []interface{}{"k", "n", "missing"}, int64(2)},
+		{"DBSIZE", nil, int64(1)},
+		{"NOSUCH", nil, redis.Error("ERR unknown command 'nosuch'")},
+		{"GET", nil, redis.Error("ERR wrong number of arguments for 'get' command")},
+	}
+	for _, tt := range tests {
+		got, err := conn.Do(tt.cmd, tt.args...)
+		if err != nil {
+			got = err
+		}
+		if !equalReply(got, tt.want) {
+			t.Errorf("%s %v = %#v, want %#v", tt.cmd, tt.args, got, tt.want)
+		}
+	}
+}
+
+func equalReply(got, want interface{}) bool {
+	if g, ok := got.([]byte); ok {
+		w, ok := want.([]byte)
+		return ok && string(g) == string(w)
+	}
+	return got == want
+}
+
+func TestFakeServerScanAndInfo(t *testing.T) {
+	conn := dialFake(t, startFakeServer(t))
+	for _, k := range []string{"key:a:1", "key:a:2", "key:b:1"} {
+		conn.Do("SET", k, "v")
+	}
+	reply, err := redis.Values(conn.Do("SCAN", 0, "MATCH", "key:a:*", "COUNT", 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := redis.Strings(reply[1], nil)
+	if len(keys) != 2 {
+		t.Errorf("SCAN matched %v, want the two keys of a", keys)
+	}
+	version, err := serverVersion(conn)
+	if err != nil || version != FakeServerVersion {
+		t.Errorf("serverVersion = %q, %v, want %q", version, err, FakeServerVersion)
+	}
+}
+
+func TestFakeServerPipeline(t *testing.T) {
+	conn := dialFake(t, startFakeServer(t))
+	for i := 0; i < 50; i++ {
+		conn.Send("INCR", "n")
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 50; i++ {
+		n, err := redis.Int(conn.Receive())
+		if err != nil || n != i {
+			t.Fatalf("reply %d of the pipeline = %d, %v", i, n, err)
+		}
+	}
+}
+
+func TestFakeServerInjection(t *testing.T) {
+	server := NewFakeServer()
+	server.Latency = 20 * time.Millisecond
+	server.ErrorRate = 1
+	server.Errors = []string{"LOADING Redis is loading the dataset in memory"}
+	if err := server.Listen("localhost:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	conn := dialFake(t, server)
+
+	st := time.Now()
+	_, err := conn.Do("PING")
+	if elapsed := time.Since(st); elapsed < server.Latency {
+		t.Errorf("PING took %v, want at least the injected %v", elapsed, server.Latency)
+	}
+	if classifyError(err) != "loading" {
+		t.Errorf("PING failed with %v, want the injected LOADING error", err)
+	}
+}
+
+func TestFakeList(t *testing.T) {
+	// pops cross over to the other end once their end is empty
+	l := &fakeList{}
+	for _, v := range []string{"b", "a"} {
+		l.push(true, []byte(v))
+	}
+	for _, v := range []string{"c", "d"} {
+		l.push(false, []byte(v))
+	}
+	got := ""
+	for l.len() > 0 {
+		got += string(l.pop(false))
+	}
+	l.push(false, []byte("x"))
+	l.push(false, []byte("y"))
+	got += string(l.pop(true))
+	if got != "dcbax" || l.len() != 1 {
+		t.Errorf("List popped %s and kept %d elements, want dcbax and 1", got, l.len())
+	}
+}
+
+func TestReadRESPCommandLimits(t *testing.T) {
+	for _, cmd := range []string{"*-1\r\n", "*99999999999\r\n", "*2097152\r\n",
+		"*1\r\n$-1\r\n", "*1\r\n$536870913\r\n"} {
+		if _, err := readRESPCommand(bufio.NewReader(strings.NewReader(cmd))); err == nil {
+			t.Errorf("Command %q was read", cmd)
+		}
+	}
+	get := "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n"
+	args, err := readRESPCommand(bufio.NewReader(strings.NewReader(get)))
+	if err != nil || len(args) != 2 || string(args[1]) != "k" {
+		t.Errorf("GET k was read as %q, %v", args, err)
+	}
+}
@@ -0,0 +1,351 @@
+package benchdis
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var faultPhasePattern = regexp.MustCompile(`^\s*([^\s:-]+)\s*-\s*([^\s:]*)\s*:(.+)$`)
+
+var bandwidthPattern = regexp.MustCompile(`^(?i)([0-9]*\.?[0-9]+)\s*(b|kb|mb|gb)?(/s)?$`)
+
+// bandwidthUnits are the bytes in a unit of bandwidth
+var bandwidthUnits = map[string]float64{"": 1, "b": 1, "kb": 1 << 10, "mb": 1 << 20, "gb": 1 << 30}
+
+// faultRetransmit is how long a dropped chunk is held back, like a lost packet waiting for
+// the retransmission timeout of TCP
+const faultRetransmit = 200 * time.Millisecond
+
+const faultChunkSize = 16 << 10
+
+// FaultPhase is a degradation of the network injected by the fault proxy from Start to End
+// of every test run, written like 5s-10s:latency=20ms,jitter=5ms. An empty end, like in
+// 10s-:reset=1%, keeps the faults until the run ends.
+type FaultPhase struct {
+	Spec  string
+	Start time.Duration
+	End   time.Duration
+	// Latency is added to every reply, give or take the Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// Bandwidth is the limit in bytes per second of each direction of a connection
+	Bandwidth float64
+	// Drop is the percentage of the chunks of data which are held back for the retransmission
+	// timeout, like lost packets
+	Drop float64
+	// Reset is the percentage of the chunks of data on which the connection is reset
+	Reset float64
+}
+
+// ParseFaultPhase parses a fault phase of the form <start>-[<end>]:<fault>=<value>,... where
+// the faults are latency and jitter in durations, bandwidth in bytes per second like 64KB,
+// and drop and reset in percentages
+func ParseFaultPhase(spec string) (*FaultPhase, error) {
+	m := faultPhasePattern.FindStringSubmatch(spec)
+	if m == nil {
+		return nil, fmt.Errorf(
+			"Fault %q is not valid, should be like 5s-10s:latency=20ms,jitter=5ms", spec)
+	}
+	fp := FaultPhase{Spec: strings.TrimSpace(spec)}
+	var err error
+	if fp.Start, err = time.ParseDuration(m[1]); err != nil || fp.Start < 0 {
+		return nil, fmt.Errorf("Fault %q has an invalid start %s", spec, m[1])
+	}
+	if len(m[2]) > 0 {
+		if fp.End, err = time.ParseDuration(m[2]); err != nil || fp.End <= fp.Start {
+			return nil, fmt.Errorf("Fault %q has an invalid end %s, should be after the start",
+				spec, m[2])
+		}
+	}
+	for _, f := range strings.Split(m[3], ",") {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Fault %q has %q without a value", spec, f)
+		}
+		name, value := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		switch name {
+		case "latency", "jitter":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("Fault %q has an invalid %s %s", spec, name, value)
+			}
+			if name == "latency" {
+				fp.Latency = d
+			} else {
+				fp.Jitter = d
+			}
+		case "bandwidth":
+			bm := bandwidthPattern.FindStringSubmatch(value)
+			if bm == nil {
+				return nil, fmt.Errorf("Fault %q has an invalid bandwidth %s, should be like 64KB",
+					spec, value)
+			}
+			n, _ := strconv.ParseFloat(bm[1], 64)
+			fp.Bandwidth = n * bandwidthUnits[strings.ToLower(bm[2])]
+			if fp.Bandwidth <= 0 {
+				return nil, fmt.Errorf("Fault %q has a bandwidth of zero", spec)
+			}
+		case "drop", "reset":
+			p, err := ParsePercent(value)
+			if err != nil || p < 0 || p > 100 {
+				return nil, fmt.Errorf("Fault %q has an invalid %s %s, should be a percentage",
+					spec, name, value)
+			}
+			if name == "drop" {
+				fp.Drop = p
+			} else {
+				fp.Reset = p
+			}
+		default:
+			return nil, fmt.Errorf("Fault %q has unknown fault %s, should be one of latency, "+
+				"jitter, bandwidth, drop or reset", spec, name)
+		}
+	}
+	return &fp, nil
+}
+
+// ParseFaultPhases parses all the fault phases of a schedule
+func ParseFaultPhases(specs []string) ([]*FaultPhase, error) {
+	phases := make([]*FaultPhase, 0, len(specs))
+	for _, s := range specs {
+		fp, err := ParseFaultPhase(s)
+		if err != nil {
+			return nil, err
+		}
+		phases = append(phases, fp)
+	}
+	return phases, nil
+}
+
+// activeBetween tells if the phase is active at any time between the offsets from the start
+// of the run
+func (fp *FaultPhase) activeBetween(from, to time.Duration) bool {
+	return fp.Start < to && (fp.End == 0 || fp.End > from)
+}
+
+// activeFaults returns the specs of the phases active at any time between the offsets
+func activeFaults(phases []*FaultPhase, from, to time.Duration) []string {
+	var specs []string
+	for _, fp := range phases {
+		if fp.activeBetween(from, to) {
+			specs = append(specs, fp.Spec)
+		}
+	}
+	return specs
+}
+
+// FaultProxy is a TCP proxy between the clients and the server which degrades the network
+// following a schedule of fault phases. The schedule starts over with every test run, and
+// no faults are injected outside of the runs, so that the setup and the cleanup are not
+// disturbed.
+type FaultProxy struct {
+	Target string
+	Phases []*FaultPhase
+
+	listener net.Listener
+	start    time.Time
+	running  bool
+	rand     *rand.Rand
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+}
+
+// NewFaultProxy creates the proxy to the target address with the schedule of fault phases
+func NewFaultProxy(target string, phases []*FaultPhase) *FaultProxy {
+	return &FaultProxy{
+		Target: target,
+		Phases: phases,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen starts proxying the connections made to the address, like localhost:0 for any free
+// port, until the proxy is closed
+func (fp *FaultProxy) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	fp.listener = listener
+	fp.wg.Add(1)
+	go fp.accept()
+	return nil
+}
+
+// Addr is the address the proxy listens on
+func (fp *FaultProxy) Addr() string {
+	return fp.listener.Addr().String()
+}
+
+// Begin starts the schedule of the faults, at the start of a test run
+func (fp *FaultProxy) Begin() {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	fp.start = time.Now()
+	fp.running = true
+}
+
+// End stops injecting faults, at the end of a test run
+func (fp *FaultProxy) End() {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	fp.running = false
+}
+
+// Close stops the proxy and closes all its connections
+func (fp *FaultProxy) Close() error {
+	fp.mutex.Lock()
+	fp.closed = true
+	err := fp.listener.Close()
+	for conn := range fp.conns {
+		conn.Close()
+	}
+	fp.mutex.Unlock()
+	fp.wg.Wait()
+	return err
+}
+
+func (fp *FaultProxy) accept() {
+	defer fp.wg.Done()
+	for {
+		client, err := fp.listener.Accept()
+		if err != nil {
+			return
+		}
+		fp.wg.Add(1)
+		go fp.proxy(client)
+	}
+}
+
+// track adds the connection to the ones closed with the proxy, or tells that the proxy is
+// closed already
+func (fp *FaultProxy) track(conn net.Conn) bool {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	if fp.closed {
+		return false
+	}
+	fp.conns[conn] = struct{}{}
+	return true
+}
+
+func (fp *FaultProxy) untrack(conn net.Conn) {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	delete(fp.conns, conn)
+}
+
+func (fp *FaultProxy) proxy(client net.Conn) {
+	defer fp.wg.Done()
+	defer client.Close()
+	if !fp.track(client) {
+		return
+	}
+	defer fp.untrack(client)
+	server, err := net.Dial("tcp", fp.Target)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+	if !fp.track(server) {
+		return
+	}
+	defer fp.untrack(server)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		fp.pump(server, client, false)
+		done <- struct{}{}
+	}()
+	go func() {
+		fp.pump(client, server, true)
+		done <- struct{}{}
+	}()
+	// once either side is gone the other is closed too
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+}
+
+// pump copies the data from src to dst through the active faults. The latency is only added
+// to the replies, so that it adds up to the round trip time of the requests.
+func (fp *FaultProxy) pump(dst, src net.Conn, replies bool) {
+	buf := make([]byte, faultChunkSize)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			delay, reset := fp.inject(n, replies)
+			if reset {
+				resetConn(src)
+				resetConn(dst)
+				return
+			}
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				if tcp, ok := dst.(*net.TCPConn); ok {
+					tcp.CloseWrite()
+				}
+			}
+			return
+		}
+	}
+}
+
+// inject decides the faults for a chunk of n bytes, returning how long it is held back and
+// whether the connection is reset instead
+func (fp *FaultProxy) inject(n int, replies bool) (time.Duration, bool) {
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+	if !fp.running {
+		return 0, false
+	}
+	offset := time.Since(fp.start)
+	var delay time.Duration
+	for _, phase := range fp.Phases {
+		if !phase.activeBetween(offset, offset+1) {
+			continue
+		}
+		if phase.Reset > 0 && fp.rand.Float64()*100 < phase.Reset {
+			return 0, true
+		}
+		if phase.Drop > 0 && fp.rand.Float64()*100 < phase.Drop {
+			delay += faultRetransmit
+		}
+		if replies {
+			delay += phase.Latency
+			if phase.Jitter > 0 {
+				delay += time.Duration(fp.rand.Int63n(int64(2*phase.Jitter))) - phase.Jitter
+			}
+		}
+		if phase.Bandwidth > 0 {
+			delay += time.Duration(float64(n) / phase.Bandwidth * float64(time.Second))
+		}
+	}
+	return delay, false
+}
+
+// resetConn closes the connection with a TCP reset rather than a graceful close
+func resetConn(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,133 @@
+package benchdis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestParseFaultPhase(t *testing.T) {
+	fp, err := ParseFaultPhase("5s-10s: latency=20ms, jitter=5ms,bandwidth=64KB/s,drop=1%,reset=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := FaultPhase{
+		Spec:      "5s-10s: latency=20ms, jitter=5ms,bandwidth=64KB/s,drop=1%,reset=0.5",
+		Start:     5 * time.Second,
+		End:       10 * time.Second,
+		Latency:   20 * time.Millisecond,
+		Jitter:    5 * time.Millisecond,
+		Bandwidth: 64 << 10,
+		Drop:      1,
+		Reset:     0.5,
+	}
+	if !reflect.DeepEqual(*fp, want) {
+		t.Errorf("ParseFaultPhase = %+v, want %+v", *fp, want)
+	}
+
+	for _, spec := range []string{"latency=1ms", "5s:latency=1ms", "5s-2s:latency=1ms",
+		"0s-:latency", "0s-:latency=fast", "0s-:drop=120%", "0s-:bandwidth=0", "0s-:loss=1%"} {
+		if _, err := ParseFaultPhase(spec); err == nil {
+			t.Errorf("ParseFaultPhase(%q) did not fail", spec)
+		}
+	}
+}
+
+func TestActiveFaults(t *testing.T) {
+	phases, err := ParseFaultPhases([]string{"1s-2s:latency=1ms", "2s-:reset=1%"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, to time.Duration
+		want     []string
+	}{
+		{0, time.Second, nil},
+		{500 * time.Millisecond, 1500 * time.Millisecond, []string{"1s-2s:latency=1ms"}},
+		{time.Second, 3 * time.Second, []string{"1s-2s:latency=1ms", "2s-:reset=1%"}},
+		{time.Hour, 2 * time.Hour, []string{"2s-:reset=1%"}},
+	}
+	for _, tt := range tests {
+		if got := activeFaults(phases, tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("activeFaults(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestFaultProxy(t *testing.T) {
+	server := startFakeServer(t)
+	phases, _ := ParseFaultPhases([]string{"0s-:latency=30ms"})
+	proxy := NewFaultProxy(server.Addr(), phases)
+	if err := proxy.Listen("localhost:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	conn, err := redis.Dial("tcp", proxy.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ping := func() time.Duration {
+		st := time.Now()
+		if _, err := conn.Do("PING"); err != nil {
+			t.Fatalf("PING through the proxy failed: %v", err)
+		}
+		return time.Since(st)
+	}
+	if d := ping(); d >= 30*time.Millisecond {
+		t.Errorf("PING took %v before the run began", d)
+	}
+	proxy.Begin()
+	if d := ping(); d < 30*time.Millisecond {
+		t.Errorf("PING took %v with 30ms of latency injected", d)
+	}
+	proxy.End()
+	if d := ping(); d >= 30*time.Millisecond {
+		t.Errorf("PING took %v after the run ended", d)
+	}
+}
+
+func TestRunnerWithFaults(t *testing.T) {
+	server := startFakeServer(t)
+	conf := testConfig(t, server)
+	conf.NReqs = 2000
+	conf.Tests = []string{"set"}
+	conf.Interval = 20 * time.Millisecond
+	conf.Faults = []string{"0s-:reset=1%"}
+
+	runner := NewRunner(conf)
+	report, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := report.Results[0]
+	if br.ErrorCount == 0 || br.Errors["conn_reset"] == nil {
+		t.Errorf("Run has errors %v, want connection resets", br.Errors)
+	}
+	// the clients reconnect, so most of the requests still go through
+	if br.ErrorCount > conf.NReqs/2 {
+		t.Errorf("Run has %d errors in %d requests", br.ErrorCount, conf.NReqs)
+	}
+	for _, is := range br.TimeSeries {
+		if !reflect.DeepEqual(is.Faults, conf.Faults) {
+			t.Errorf("Interval at %vs has faults %v, want %v", is.Offset, is.Faults, conf.Faults)
+		}
+	}
+	if keys := server.Keys(); keys != 0 {
+		t.Errorf("%d keys are left behind, the cleanup should bypass the faults", keys)
+	}
+
+	// the proxy belongs to the run, a later run without faults goes straight to the server
+	conf.Faults = nil
+	conf.Interval = 0
+	if report, err = runner.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if br = report.Results[0]; br.ErrorCount != 0 {
+		t.Errorf("Run without faults has errors %v", br.Errors)
+	}
+}
@@ -0,0 +1,74 @@
+package benchdis
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// gobenchPercentiles are the percentiles reported as custom metrics in the gobench format
+var gobenchPercentiles = []float64{50, 99}
+
+// GoBenchReporter reports the results in the format of go test -bench, so that the output
+// of benchdis runs can be compared with benchstat. Every run of a repeated test is written
+// as its own line for benchstat to compute the variance, and the levels of a sweep are
+// written as sub-benchmarks, like BenchmarkRedisSET/clients=4-4.
+type GoBenchReporter struct{}
+
+var _ Reporter = GoBenchReporter{}
+
+func (gr GoBenchReporter) ReportResults(rpt *Report) string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "goos: %s\n", runtime.GOOS)
+	fmt.Fprintf(&builder, "goarch: %s\n", runtime.GOARCH)
+	builder.WriteString("pkg: github.com/daichi-m/benchdis\n")
+
+	if rpt.Metadata != nil && len(rpt.Metadata.ServerVersion) > 0 {
+		fmt.Fprintf(&builder, "redis: %s\n", rpt.Metadata.ServerVersion)
+	}
+	for _, br := range rpt.Results {
+		// benchstat cannot tell partial results apart, so they are left out
+		if br.Partial {
+			continue
+		}
+		clients, reqs, size := 1, 0, 0
+		if rpt.Metadata != nil {
+			conf := levelConfig(&rpt.Metadata.Config, br.Sweep)
+			clients, reqs, size = conf.NClients, conf.NReqs, conf.ReqSize
+		}
+		name := strings.ToUpper(br.BenchTestName)
+		if len(br.Sweep) > 0 {
+			name += "/" + br.Sweep
+		}
+		runs := br.Runs
+		if len(runs) == 0 {
+			runs = []*BenchmarkResult{br}
+		}
+		bytesPerOp := 0
+		if testCarriesData(br.BenchTestName) {
+			bytesPerOp = size
+		}
+		for _, run := range runs {
+			gr.writeLine(&builder, name, clients, reqs, bytesPerOp, run)
+		}
+	}
+	return builder.String()
+}
+
+func (gr GoBenchReporter) writeLine(builder *strings.Builder, name string, clients, reqs,
+	bytesPerOp int, br *BenchmarkResult) {
+
+	n := reqs - br.ErrorCount
+	nsPerOp := 0.0
+	if br.QPS > 0 {
+		nsPerOp = 1e9 / br.QPS
+	}
+	fmt.Fprintf(builder, "BenchmarkRedis%s-%d\t%8d\t%12.1f ns/op\t%8d B/op", name, clients, n,
+		nsPerOp, bytesPerOp)
+	for _, p := range gobenchPercentiles {
+		if v, ok := br.Percentiles[percentileKey(p)]; ok {
+			fmt.Fprintf(builder, "\t%10.3f %s-ms", v, percentileKey(p))
+		}
+	}
+	fmt.Fprintf(builder, "\t%12.1f qps\n", br.QPS)
+}
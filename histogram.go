@@ -0,0 +1,68 @@
+package benchdis
+
+import (
+	"math"
+	"sort"
+)
+
+// histogramBase is the upper bound in milliseconds of the first histogram bucket. Every
+// following bucket is wider by a factor of histogramGrowth, giving ten buckets per decade.
+const histogramBase = 0.01
+
+var histogramGrowth = math.Pow(10, 0.1)
+
+// HistogramBucket is the number of requests with latency above the bound of the previous
+// bucket and at most the bound of this bucket
+type HistogramBucket struct {
+	UpperBound float64 `json:"le" yaml:"le"`
+	Count      int     `json:"count" yaml:"count"`
+}
+
+// buildHistogram buckets the latencies into log spaced buckets, skipping empty buckets
+func buildHistogram(latencies []float64) []*HistogramBucket {
+	if len(latencies) == 0 {
+		return nil
+	}
+	sorted := make([]float64, len(latencies))
+	copy(sorted, latencies)
+	sort.Float64s(sorted)
+
+	hist := make([]*HistogramBucket, 0)
+	bound := histogramBase
+	for i := 0; i < len(sorted); {
+		count := 0
+		for ; i < len(sorted) && sorted[i] <= bound; i++ {
+			count++
+		}
+		if count > 0 {
+			hist = append(hist, &HistogramBucket{UpperBound: roundBound(bound), Count: count})
+		}
+		bound = bound * histogramGrowth
+	}
+	return hist
+}
+
+// mergeHistograms adds up the counts of the buckets of all the histograms
+func mergeHistograms(hists ...[]*HistogramBucket) []*HistogramBucket {
+	counts := make(map[float64]int)
+	for _, h := range hists {
+		for _, bkt := range h {
+			counts[bkt.UpperBound] += bkt.Count
+		}
+	}
+	merged := make([]*HistogramBucket, 0, len(counts))
+	for le, c := range counts {
+		merged = append(merged, &HistogramBucket{UpperBound: le, Count: c})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].UpperBound < merged[j].UpperBound
+	})
+	return merged
+}
+
+// roundBound rounds the bucket bound to 3 significant digits so that bounds are stable
+// across runs and readable in the reports
+func roundBound(f float64) float64 {
+	scale := math.Pow(10, 2-math.Floor(math.Log10(f)))
+	return math.Round(f*scale) / scale
+}
@@ -0,0 +1,330 @@
+package benchdis
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+)
+
+const (
+	chartWidth   = 720
+	chartHeight  = 300
+	chartLeft    = 70
+	chartRight   = 20
+	chartTop     = 40
+	chartBottom  = 50
+	chartYTicks  = 5
+	chartXLabels = 12
+)
+
+var chartColors = []string{"#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948",
+	"#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"}
+
+// HtmlReporter renders the results as a single self contained HTML page with inline CSS and
+// JS, charting the QPS, the latency percentiles, the latency histograms and the time series
+type HtmlReporter struct{}
+
+var _ Reporter = HtmlReporter{}
+
+type chartSeries struct {
+	Name   string
+	Values []float64
+}
+
+type htmlTestCharts struct {
+	Test       string
+	Histogram  template.HTML
+	TimeSeries template.HTML
+	Latencies  template.HTML
+}
+
+type htmlData struct {
+	Report      *Report
+	Percentiles []string
+	Table       template.HTML
+	QPSChart    template.HTML
+	PctChart    template.HTML
+	Tests       []*htmlTestCharts
+}
+
+func (hr HtmlReporter) ReportResults(rpt *Report) string {
+
+	brs := rpt.Results
+	data := htmlData{
+		Report:      rpt,
+		Percentiles: percentileKeys(brs),
+	}
+
+	tests := make([]string, 0, len(brs))
+	qps := make([]float64, 0, len(brs))
+	for _, br := range brs {
+		tests = append(tests, br.Label())
+		qps = append(qps, br.QPS)
+	}
+	data.QPSChart = svgBarChart("Throughput per test", "req/s", tests, qps)
+
+	pctLabels := make([]string, 0, len(data.Percentiles))
+	for _, k := range data.Percentiles {
+		pctLabels = append(pctLabels, percentileHeader(k))
+	}
+	pctSeries := make([]chartSeries, 0, len(brs))
+	for _, br := range brs {
+		values := make([]float64, 0, len(data.Percentiles))
+		for _, k := range data.Percentiles {
+			values = append(values, br.Percentiles[k])
+		}
+		pctSeries = append(pctSeries, chartSeries{Name: br.Label(), Values: values})
+	}
+	if len(pctLabels) > 0 {
+		data.PctChart = svgLineChart("Latency percentiles", "ms", pctLabels, pctSeries)
+	}
+
+	for _, br := range brs {
+		tc := htmlTestCharts{Test: br.Label()}
+		if len(br.Histogram) > 0 {
+			labels := make([]string, 0, len(br.Histogram))
+			counts := make([]float64, 0, len(br.Histogram))
+			for _, bkt := range br.Histogram {
+				labels = append(labels, fmt.Sprintf("≤%g", bkt.UpperBound))
+				counts = append(counts, float64(bkt.Count))
+			}
+			tc.Histogram = svgBarChart("Latency histogram (ms)", "requests", labels, counts)
+		}
+		if len(br.TimeSeries) > 0 {
+			labels := make([]string, 0, len(br.TimeSeries))
+			qps := chartSeries{Name: "QPS"}
+			p50 := chartSeries{Name: "P50"}
+			p99 := chartSeries{Name: "P99"}
+			for _, is := range br.TimeSeries {
+				label := fmt.Sprintf("%gs", is.Offset)
+				if is.Run > 0 {
+					label = fmt.Sprintf("#%d %s", is.Run, label)
+				}
+				labels = append(labels, label)
+				qps.Values = append(qps.Values, is.QPS)
+				p50.Values = append(p50.Values, is.P50)
+				p99.Values = append(p99.Values, is.P99)
+			}
+			tc.TimeSeries = svgLineChart("Throughput over time", "req/s", labels,
+				[]chartSeries{qps})
+			tc.Latencies = svgLineChart("Latency over time", "ms", labels,
+				[]chartSeries{p50, p99})
+		}
+		data.Tests = append(data.Tests, &tc)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := htmlReportTemplate.Execute(buffer, data); err != nil {
+		return fmt.Sprintf("<!-- Cannot render report: %s -->", html.EscapeString(err.Error()))
+	}
+	return buffer.String()
+}
+
+// niceMax rounds the maximum of a chart axis up to 1, 2, 5 or 10 times a power of 10
+func niceMax(f float64) float64 {
+	if f <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(f)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if f <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+// svgFrame draws the title, the y axis grid lines with their labels and the x axis labels,
+// and returns the builder along with the y scale of the chart
+func svgFrame(title, unit string, labels []string, max float64) (*strings.Builder,
+	func(float64) float64, func(int) float64) {
+
+	builder := new(strings.Builder)
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+	max = niceMax(max)
+	yScale := func(v float64) float64 {
+		return float64(chartTop) + plotH - v/max*plotH
+	}
+	xScale := func(i int) float64 {
+		return float64(chartLeft) + (float64(i)+0.5)*plotW/float64(len(labels))
+	}
+
+	fmt.Fprintf(builder, `<svg class="chart" viewBox="0 0 %d %d" `+
+		`xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+	fmt.Fprintf(builder, `<text class="title" x="%d" y="20">%s</text>`, chartLeft,
+		html.EscapeString(title))
+	fmt.Fprintf(builder, `<text class="unit" x="10" y="%d">%s</text>`, chartTop-10,
+		html.EscapeString(unit))
+	for t := 0; t <= chartYTicks; t++ {
+		v := max * float64(t) / chartYTicks
+		y := yScale(v)
+		fmt.Fprintf(builder, `<line class="grid" x1="%d" x2="%d" y1="%0.1f" y2="%0.1f"/>`,
+			chartLeft, chartWidth-chartRight, y, y)
+		fmt.Fprintf(builder, `<text class="ylabel" x="%d" y="%0.1f">%.4g</text>`, chartLeft-6,
+			y+4, v)
+	}
+	step := int(math.Ceil(float64(len(labels)) / chartXLabels))
+	for i, l := range labels {
+		if i%step != 0 {
+			continue
+		}
+		fmt.Fprintf(builder, `<text class="xlabel" x="%0.1f" y="%d">%s</text>`, xScale(i),
+			chartHeight-chartBottom+18, html.EscapeString(l))
+	}
+	return builder, yScale, xScale
+}
+
+// svgBarChart renders a bar chart of the values as an inline SVG
+func svgBarChart(title, unit string, labels []string, values []float64) template.HTML {
+	max := 0.0
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	builder, yScale, xScale := svgFrame(title, unit, labels, max)
+	barW := float64(chartWidth-chartLeft-chartRight) / float64(len(labels)) * 0.7
+	for i, v := range values {
+		y := yScale(v)
+		fmt.Fprintf(builder, `<rect class="bar" x="%0.1f" y="%0.1f" width="%0.1f" `+
+			`height="%0.1f" fill="%s"><title>%s: %0.3f %s</title></rect>`,
+			xScale(i)-barW/2, y, barW, yScale(0)-y, chartColors[0],
+			html.EscapeString(labels[i]), v, html.EscapeString(unit))
+	}
+	builder.WriteString("</svg>")
+	return template.HTML(builder.String())
+}
+
+// svgLineChart renders a line chart with one line per series as an inline SVG. The legend
+// entries toggle the visibility of their series.
+func svgLineChart(title, unit string, labels []string, series []chartSeries) template.HTML {
+	max := 0.0
+	for _, s := range series {
+		for _, v := range s.Values {
+			max = math.Max(max, v)
+		}
+	}
+	builder, yScale, xScale := svgFrame(title, unit, labels, max)
+	for si, s := range series {
+		color := chartColors[si%len(chartColors)]
+		points := make([]string, 0, len(s.Values))
+		for i, v := range s.Values {
+			points = append(points, fmt.Sprintf("%0.1f,%0.1f", xScale(i), yScale(v)))
+		}
+		fmt.Fprintf(builder, `<g class="series" data-series="%d">`, si)
+		fmt.Fprintf(builder, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`,
+			color, strings.Join(points, " "))
+		for i, v := range s.Values {
+			fmt.Fprintf(builder, `<circle cx="%0.1f" cy="%0.1f" r="3" fill="%s">`+
+				`<title>%s %s: %0.3f %s</title></circle>`, xScale(i), yScale(v), color,
+				html.EscapeString(s.Name), html.EscapeString(labels[i]), v,
+				html.EscapeString(unit))
+		}
+		builder.WriteString("</g>")
+		fmt.Fprintf(builder, `<g class="legend" data-series="%d">`+
+			`<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+
+			`<text x="%d" y="%d">%s</text></g>`, si, chartWidth-chartRight-120, 12+si*16,
+			color, chartWidth-chartRight-102, 22+si*16, html.EscapeString(s.Name))
+	}
+	builder.WriteString("</svg>")
+	return template.HTML(builder.String())
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"upper": strings.ToUpper,
+	"pct":   func(br *BenchmarkResult, k string) float64 { return br.Percentiles[k] },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>benchdis report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em;
+  color: #222; }
+h1, h2, h3 { font-weight: 500; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ddd; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { background: #f5f5f5; }
+.meta td { text-align: left; }
+.chart { width: 100%; max-width: 720px; display: block; margin: 1em 0; }
+.chart .title { font-size: 14px; font-weight: bold; }
+.chart .unit, .chart .ylabel, .chart .xlabel { font-size: 10px; fill: #555; }
+.chart .ylabel { text-anchor: end; }
+.chart .xlabel { text-anchor: middle; }
+.chart .grid { stroke: #eee; }
+.chart .legend { cursor: pointer; font-size: 11px; }
+.chart .legend.off { opacity: 0.3; }
+.chart .series.off { display: none; }
+.regression { color: #c00; font-weight: bold; }
+.charts { display: flex; flex-wrap: wrap; gap: 1em; }
+</style>
+</head>
+<body>
+<h1>benchdis report</h1>
+{{with .Report.Metadata}}
+<table class="meta">
+<tr><th>benchdis version</th><td>{{.Version}}</td></tr>
+<tr><th>Server version</th><td>{{.ServerVersion}}</td></tr>
+<tr><th>Timestamp</th><td>{{.Timestamp.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+<tr><th>Host</th><td>{{.Hostname}}</td></tr>
+<tr><th>Target</th><td>{{.Config.Host}}:{{.Config.Port}}/{{.Config.Database}}</td></tr>
+<tr><th>Clients</th><td>{{.Config.NClients}}</td></tr>
+<tr><th>Requests</th><td>{{.Config.NReqs}}</td></tr>
+<tr><th>Data size</th><td>{{.Config.ReqSize}} bytes ({{.Config.Payload}})</td></tr>
+<tr><th>Duration</th><td>{{printf "%0.1f" .Duration}}s</td></tr>
+</table>
+{{end}}
+<h2>Results</h2>
+<table>
+<tr><th>Test</th><th>QPS (req/s)</th><th>Min (ms)</th><th>Avg (ms)</th>
+{{- range .Percentiles}}<th>{{upper .}} (ms)</th>{{end}}<th>Max (ms)</th><th>Errors</th></tr>
+{{- $pcts := .Percentiles}}
+{{- range .Report.Results}}
+<tr><td>{{.Label}}</td><td>{{printf "%0.3f" .QPS}}</td>
+<td>{{printf "%0.3f" .MinLatency}}</td><td>{{printf "%0.3f" .AvgLatency}}</td>
+{{- $br := .}}{{range $pcts}}<td>{{printf "%0.3f" (pct $br .)}}</td>{{end}}
+<td>{{printf "%0.3f" .MaxLatency}}</td><td>{{.ErrorCount}}</td></tr>
+{{- end}}
+</table>
+{{with .Report.Comparison}}
+<h2>Comparison against baseline</h2>
+<table>
+<tr><th>Test</th><th>Metric</th><th>Baseline</th><th>Current</th><th>Change</th></tr>
+{{- range .Tests}}{{$test := .Test}}{{range .Deltas}}
+<tr{{if .Regression}} class="regression"{{end}}><td>{{$test}}</td><td>{{upper .Metric}}</td>
+<td>{{printf "%0.3f" .Old}}</td><td>{{printf "%0.3f" .New}}</td>
+<td>{{printf "%+0.2f%%" .Change}}</td></tr>
+{{- end}}{{end}}
+</table>
+{{end}}
+<h2>Charts</h2>
+<div class="charts">
+{{.QPSChart}}
+{{.PctChart}}
+</div>
+{{range .Tests}}
+<h3>{{upper .Test}}</h3>
+<div class="charts">
+{{.Histogram}}
+{{.TimeSeries}}
+{{.Latencies}}
+</div>
+{{end}}
+<script>
+document.querySelectorAll(".chart .legend").forEach(function (legend) {
+  legend.addEventListener("click", function () {
+    var chart = legend.closest("svg");
+    var idx = legend.getAttribute("data-series");
+    legend.classList.toggle("off");
+    chart.querySelectorAll('.series[data-series="' + idx + '"]').forEach(function (s) {
+      s.classList.toggle("off");
+    });
+  });
+});
+</script>
+</body>
+</html>
+`))
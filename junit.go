@@ -0,0 +1,125 @@
+package benchdis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// JUnitReporter reports every test as a JUnit testcase which fails when any of the SLOs
+// asserted on the test fail, so that CI systems can show the results natively
+type JUnitReporter struct{}
+
+var _ Reporter = JUnitReporter{}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Hostname   string          `xml:"hostname,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Time      string         `xml:"time,attr"`
+	Failures  []junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure  `xml:"error,omitempty"`
+	SystemOut string         `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (jr JUnitReporter) ReportResults(rpt *Report) string {
+	suite := junitTestSuite{Name: "benchdis"}
+	reqs := 0
+	if rpt.Metadata != nil {
+		suite.Timestamp = rpt.Metadata.Timestamp.Format("2006-01-02T15:04:05")
+		suite.Hostname = rpt.Metadata.Hostname
+		suite.Time = fmt.Sprintf("%0.3f", rpt.Metadata.Duration)
+		reqs = rpt.Metadata.Config.NReqs
+		suite.Properties = []junitProperty{
+			{Name: "version", Value: rpt.Metadata.Version},
+			{Name: "server_version", Value: rpt.Metadata.ServerVersion},
+			{Name: "clients", Value: fmt.Sprintf("%d", rpt.Metadata.Config.NClients)},
+			{Name: "requests", Value: fmt.Sprintf("%d", reqs)},
+			{Name: "data_size", Value: fmt.Sprintf("%d", rpt.Metadata.Config.ReqSize)},
+		}
+	}
+
+	for _, br := range rpt.Results {
+		tc := junitTestCase{
+			Name:      br.BenchTestName,
+			ClassName: "benchdis." + br.BenchTestName,
+			Time:      "0.000",
+		}
+		if br.QPS > 0 && reqs > 0 {
+			tc.Time = fmt.Sprintf("%0.3f", float64(reqs)/br.QPS)
+		}
+		checks := make([]string, 0)
+		for _, sr := range rpt.SLOs {
+			if sr.Test != br.BenchTestName {
+				continue
+			}
+			if sr.Passed {
+				checks = append(checks, "PASS "+sr.Message)
+				continue
+			}
+			checks = append(checks, "FAIL "+sr.Message)
+			tc.Failures = append(tc.Failures, junitFailure{
+				Message: sr.Message,
+				Type:    "SLO",
+				Text:    sr.Assertion,
+			})
+		}
+		tc.SystemOut = fmt.Sprintf("qps=%0.3f avg=%0.3fms max=%0.3fms errors=%d\n%s",
+			br.QPS, br.AvgLatency, br.MaxLatency, br.ErrorCount, strings.Join(checks, "\n"))
+		if br.Partial {
+			tc.Error = &junitFailure{
+				Message: "Run was interrupted, the results are partial",
+				Type:    "Interrupted",
+			}
+			suite.Errors++
+		}
+		suite.Tests++
+		if len(tc.Failures) > 0 {
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	suites := junitTestSuites{
+		Name:     "benchdis",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	x, _ := xml.MarshalIndent(suites, "", "  ")
+	return xml.Header + string(x)
+}
@@ -1,4 +1,4 @@
-package main
+package benchdis
 
 import (
 	"fmt"
@@ -7,6 +7,8 @@ import (
 	"strings"
 )
 
+// Logger writes the progress of a run. All the methods except Fatalf can be called on a nil
+// Logger, which discards everything.
 type Logger struct {
 	*Config
 	io.WriteCloser
@@ -21,7 +23,7 @@ func NewLogger(conf *Config, writer io.WriteCloser) *Logger {
 }
 
 func (l *Logger) Debugf(f string, a ...interface{}) {
-	if !l.Debug {
+	if l == nil || !l.Debug {
 		return
 	}
 	f = "[DEBUG] " + l.ensureNewLine(f)
@@ -29,14 +31,25 @@ func (l *Logger) Debugf(f string, a ...interface{}) {
 }
 
 func (l *Logger) Infof(f string, a ...interface{}) {
-	if l.Quiet {
+	if l == nil || l.Quiet {
 		return
 	}
 	f = l.ensureNewLine(f)
 	fmt.Fprintf(l.WriteCloser, f, a...)
 }
 
+func (l *Logger) Warnf(f string, a ...interface{}) {
+	if l == nil {
+		return
+	}
+	f = "[WARN] " + l.ensureNewLine(f)
+	fmt.Fprintf(l.WriteCloser, f, a...)
+}
+
 func (l *Logger) Errorf(f string, a ...interface{}) {
+	if l == nil {
+		return
+	}
 	f = "[ERROR] " + l.ensureNewLine(f)
 	fmt.Fprintf(l.WriteCloser, f, a...)
 }
@@ -54,6 +67,11 @@ func (l *Logger) ensureNewLine(f string) string {
 	return f
 }
 
+// Close closes the writer of the logger, except the standard output and error of the
+// process, which stay open for whatever is printed after, like the trace of a panic
 func (l *Logger) Close() {
+	if l.WriteCloser == os.Stderr || l.WriteCloser == os.Stdout {
+		return
+	}
 	l.WriteCloser.Close()
 }
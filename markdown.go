@@ -0,0 +1,146 @@
+package benchdis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MarkdownReporter reports the results as a GitHub flavored markdown table, with the change
+// against the baseline annotated in every cell when a comparison is available
+type MarkdownReporter struct{}
+
+var _ Reporter = MarkdownReporter{}
+var _ ComparisonReporter = MarkdownReporter{}
+
+func (mr MarkdownReporter) ReportResults(rpt *Report) string {
+
+	brs := rpt.Results
+	builder := strings.Builder{}
+	builder.WriteString("## Benchmark results\n\n")
+
+	pcts := percentileKeys(brs)
+	header := []string{"Test", "QPS (req/s)", "Min (ms)", "Avg (ms)"}
+	for _, k := range pcts {
+		header = append(header, percentileHeader(k)+" (ms)")
+	}
+	header = append(header, "Max (ms)", "Errors")
+	mr.writeRow(&builder, header)
+	align := make([]string, len(header))
+	align[0] = ":---"
+	for i := 1; i < len(align); i++ {
+		align[i] = "---:"
+	}
+	mr.writeRow(&builder, align)
+
+	for _, br := range brs {
+		row := make([]string, 0, len(header))
+		row = append(row, br.Label())
+		row = append(row, mr.valueToCell(rpt.Comparison, br, "qps", br.QPS))
+		row = append(row, mr.valueToCell(nil, br, "min", br.MinLatency))
+		row = append(row, mr.valueToCell(rpt.Comparison, br, "avg", br.AvgLatency))
+		for _, k := range pcts {
+			row = append(row, mr.valueToCell(rpt.Comparison, br, k, br.Percentiles[k]))
+		}
+		row = append(row, mr.valueToCell(rpt.Comparison, br, "max", br.MaxLatency))
+		row = append(row, fmt.Sprintf("%d", br.ErrorCount))
+		mr.writeRow(&builder, row)
+	}
+
+	if rpt.Comparison != nil {
+		verdict := "no regressions"
+		if rpt.Comparison.Regressed {
+			verdict = "**regressed**"
+		}
+		builder.WriteString(fmt.Sprintf(
+			"\nChanges are against the baseline, max regression allowed %0.2f%%: %s\n",
+			rpt.Comparison.MaxRegression, verdict))
+	}
+	if rpt.Sweep != nil {
+		builder.WriteString("\n")
+		builder.WriteString(mr.sweepTable(rpt.Sweep))
+	}
+	if rpt.Metadata != nil && rpt.Metadata.Config.ReportConfig {
+		builder.WriteString("\n")
+		builder.WriteString(mr.configSummary(rpt.Metadata))
+	}
+	return builder.String()
+}
+
+func (mr MarkdownReporter) ReportComparison(c *Comparison) string {
+
+	builder := strings.Builder{}
+	builder.WriteString("## Comparison against baseline\n\n")
+	mr.writeRow(&builder, []string{"Test", "Metric", "Baseline", "Current", "Change",
+		"Status"})
+	mr.writeRow(&builder, []string{":---", ":---", "---:", "---:", "---:", ":---"})
+	for _, tc := range c.Tests {
+		for _, d := range tc.Deltas {
+			status := ""
+			if d.Regression {
+				status = "❌ regression"
+			} else if d.Gated {
+				status = "✅"
+			}
+			mr.writeRow(&builder, []string{tc.Test, summaryHeader(d.Metric),
+				fmt.Sprintf("%0.3f", d.Old), fmt.Sprintf("%0.3f", d.New),
+				fmt.Sprintf("%+0.2f%%", d.Change), status})
+		}
+	}
+	for _, test := range c.Missing {
+		mr.writeRow(&builder, []string{test, "", "", "NA", "", "❌ missing"})
+	}
+	return builder.String()
+}
+
+// configSummary renders the metadata and the config of the run as a collapsible block
+func (mr MarkdownReporter) configSummary(meta *RunMetadata) string {
+	conf := meta.Config
+	builder := strings.Builder{}
+	builder.WriteString("<details>\n<summary>Configuration</summary>\n\n")
+	mr.writeRow(&builder, []string{"Setting", "Value"})
+	mr.writeRow(&builder, []string{":---", ":---"})
+	settings := [][]string{
+		{"benchdis version", meta.Version},
+		{"Redis version", meta.ServerVersion},
+		{"Timestamp", meta.Timestamp.Format("2006-01-02 15:04:05 MST")},
+		{"Target", fmt.Sprintf("%s:%d/%d", conf.Host, conf.Port, conf.Database)},
+		{"Clients", fmt.Sprintf("%d", conf.NClients)},
+		{"Pool size", fmt.Sprintf("%d", conf.NPool)},
+		{"Requests", fmt.Sprintf("%d", conf.NReqs)},
+		{"Data size", fmt.Sprintf("%d bytes", conf.ReqSize)},
+		{"Payload", conf.Payload},
+		{"Runs of each test", fmt.Sprintf("%d", conf.Repeat)},
+		{"Tests", strings.Join(conf.Tests, ", ")},
+	}
+	for _, s := range settings {
+		mr.writeRow(&builder, s)
+	}
+	builder.WriteString("\n</details>\n")
+	return builder.String()
+}
+
+// valueToCell formats the value of a metric, annotated with the change against the baseline
+// if the metric was compared
+func (mr MarkdownReporter) valueToCell(c *Comparison, br *BenchmarkResult, metric string,
+	f float64) string {
+
+	if f == 0.0 {
+		return "NA"
+	}
+	cell := fmt.Sprintf("%0.3f", f)
+	if d, ok := c.Delta(br.BenchTestName, metric); ok {
+		cell = fmt.Sprintf("%s (%+0.1f%%)", cell, d.Change)
+		if d.Regression {
+			cell = fmt.Sprintf("**%s** ❌", cell)
+		}
+	}
+	return cell
+}
+
+func (mr MarkdownReporter) writeRow(builder *strings.Builder, cells []string) {
+	escaped := make([]string, len(cells))
+	for i, c := range cells {
+		escaped[i] = strings.ReplaceAll(c, "|", "\\|")
+	}
+	builder.WriteString("| " + strings.Join(escaped, " | ") + " |\n")
+}
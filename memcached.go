@@ -0,0 +1,274 @@
+package benchdis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memcachedTests are the tests which map onto the memcached text protocol
+var memcachedTests = []string{"ping", "set", "get", "incr"}
+
+// MemcachedError is an error reply of the memcached server, like SERVER_ERROR out of memory
+type MemcachedError string
+
+func (me MemcachedError) Error() string {
+	return string(me)
+}
+
+// MemcachedDriver benchmarks memcached over its text protocol. The get, set and incr tests
+// are sent as the memcached commands of the same name, ping as version and the cleanup of
+// the keys as delete.
+type MemcachedDriver struct {
+	address string
+	timeout time.Duration
+	idle    chan *memcachedConn
+	closed  bool
+	mutex   sync.Mutex
+}
+
+var _ Driver = &MemcachedDriver{}
+
+// NewMemcachedDriver creates the memcached driver which keeps up to Config.NPool idle
+// connections
+func NewMemcachedDriver(conf *Config) *MemcachedDriver {
+	return &MemcachedDriver{
+		address: dialAddress(conf),
+		timeout: conf.Timeout,
+		idle:    make(chan *memcachedConn, conf.NPool),
+	}
+}
+
+// Dial returns an idle connection, or a new one which connects on its first command
+func (md *MemcachedDriver) Dial() (DriverConn, error) {
+	select {
+	case mc := <-md.idle:
+		return mc, nil
+	default:
+		return &memcachedConn{driver: md}, nil
+	}
+}
+
+func (md *MemcachedDriver) Close() error {
+	md.mutex.Lock()
+	defer md.mutex.Unlock()
+	if md.closed {
+		return nil
+	}
+	md.closed = true
+	for {
+		select {
+		case mc := <-md.idle:
+			mc.closeNet()
+		default:
+			return nil
+		}
+	}
+}
+
+// release gives a connection back to the idle connections, or closes it if there are
+// enough idle connections or the driver is closed
+func (md *MemcachedDriver) release(mc *memcachedConn) {
+	md.mutex.Lock()
+	defer md.mutex.Unlock()
+	if md.closed || mc.conn == nil {
+		mc.closeNet()
+		return
+	}
+	select {
+	case md.idle <- mc:
+	default:
+		mc.closeNet()
+	}
+}
+
+type memcachedConn struct {
+	driver *MemcachedDriver
+	conn   net.Conn
+	rw     *bufio.ReadWriter
+}
+
+var _ DriverConn = &memcachedConn{}
+
+func (mc *memcachedConn) connect() error {
+	if mc.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", mc.driver.address, mc.driver.timeout)
+	if err != nil {
+		return err
+	}
+	mc.conn = conn
+	mc.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	return nil
+}
+
+func (mc *memcachedConn) closeNet() {
+	if mc.conn != nil {
+		mc.conn.Close()
+		mc.conn, mc.rw = nil, nil
+	}
+}
+
+func (mc *memcachedConn) Close() error {
+	mc.driver.release(mc)
+	return nil
+}
+
+// Err is always nil, since the connection reconnects on its own after an i/o error
+func (mc *memcachedConn) Err() error {
+	return nil
+}
+
+func (mc *memcachedConn) Execute(cmd *Command) (interface{}, error) {
+	replies := mc.Pipeline([]*Command{cmd})
+	return replies[0].Value, replies[0].Err
+}
+
+func (mc *memcachedConn) Pipeline(cmds []*Command) []Reply {
+	replies := make([]Reply, len(cmds))
+	if err := mc.connect(); err != nil {
+		return failedReplies(replies, err)
+	}
+	if mc.driver.timeout > 0 {
+		mc.conn.SetDeadline(time.Now().Add(mc.driver.timeout))
+	}
+	for _, cmd := range cmds {
+		if err := writeMemcached(mc.rw.Writer, cmd); err != nil {
+			mc.closeNet()
+			return failedReplies(replies, err)
+		}
+	}
+	if err := mc.rw.Flush(); err != nil {
+		mc.closeNet()
+		return failedReplies(replies, err)
+	}
+	for i, cmd := range cmds {
+		replies[i].Value, replies[i].Err = readMemcached(mc.rw.Reader, cmd)
+		if _, ok := replies[i].Err.(MemcachedError); replies[i].Err != nil && !ok {
+			// the connection is out of step with the replies after an i/o error
+			mc.closeNet()
+			failedReplies(replies[i:], replies[i].Err)
+			break
+		}
+	}
+	return replies
+}
+
+func (mc *memcachedConn) ServerVersion() (string, error) {
+	v, err := mc.Execute(&Command{Name: "PING"})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(v), nil
+}
+
+// writeMemcached writes the redis command as the memcached command of the same meaning
+func writeMemcached(w *bufio.Writer, cmd *Command) error {
+	arg := func(i int) []byte {
+		if i >= len(cmd.Args) {
+			return nil
+		}
+		switch a := cmd.Args[i].(type) {
+		case []byte:
+			return a
+		case string:
+			return []byte(a)
+		default:
+			return []byte(fmt.Sprint(a))
+		}
+	}
+	var err error
+	switch strings.ToUpper(cmd.Name) {
+	case "PING":
+		_, err = w.WriteString("version\r\n")
+	case "GET":
+		_, err = fmt.Fprintf(w, "get %s\r\n", arg(0))
+	case "SET":
+		value := arg(1)
+		if _, err = fmt.Fprintf(w, "set %s 0 0 %d\r\n", arg(0), len(value)); err == nil {
+			w.Write(value)
+			_, err = w.WriteString("\r\n")
+		}
+	case "INCR":
+		// incr misses counters which do not exist, so the counter is added first, the way
+		// redis creates it on the first INCR
+		_, err = fmt.Fprintf(w, "add %s 0 0 1\r\n0\r\nincr %s 1\r\n", arg(0), arg(0))
+	case "DEL":
+		_, err = fmt.Fprintf(w, "delete %s\r\n", arg(0))
+	default:
+		return fmt.Errorf("Command %s is not supported by memcached", cmd.Name)
+	}
+	return err
+}
+
+// readMemcached reads the reply to the command. Misses are returned as nil values, and the
+// error replies of the server as MemcachedError.
+func readMemcached(r *bufio.Reader, cmd *Command) (interface{}, error) {
+	line, err := readMemcachedLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToUpper(cmd.Name) == "INCR" {
+		// the reply to the add which seeds the counter comes before the one to incr
+		added := line
+		if line, err = readMemcachedLine(r); err != nil {
+			return nil, err
+		}
+		if isMemcachedError(added) {
+			return nil, MemcachedError(added)
+		}
+		if line == "NOT_FOUND" {
+			return nil, MemcachedError("NOT_FOUND counter to incr")
+		}
+	}
+	switch {
+	case isMemcachedError(line):
+		return nil, MemcachedError(line)
+	case line == "NOT_FOUND", line == "END":
+		return nil, nil
+	case strings.HasPrefix(line, "VERSION "):
+		return strings.TrimPrefix(line, "VERSION "), nil
+	case strings.HasPrefix(line, "VALUE "):
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("Malformed memcached reply %q", line)
+		}
+		size, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("Malformed memcached reply %q", line)
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		if end, err := readMemcachedLine(r); err != nil || end != "END" {
+			return nil, fmt.Errorf("Malformed memcached reply to %s", cmd.Name)
+		}
+		return value[:size], nil
+	}
+	if n, err := strconv.ParseInt(line, 10, 64); err == nil {
+		return n, nil
+	}
+	return line, nil
+}
+
+// isMemcachedError tells if the reply line is an error of the server
+func isMemcachedError(line string) bool {
+	return line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") ||
+		strings.HasPrefix(line, "SERVER_ERROR")
+}
+
+func readMemcachedLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(line, "\r\n")), nil
+}
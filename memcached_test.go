@@ -0,0 +1,201 @@
+package benchdis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMemcached is a memcached server with the text protocol commands of the driver
+type fakeMemcached struct {
+	listener net.Listener
+	items    map[string][]byte
+	// dropNext closes the connection instead of replying to the next command
+	dropNext bool
+	mutex    sync.Mutex
+}
+
+func startFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Cannot start fake memcached: %v", err)
+	}
+	fm := &fakeMemcached{listener: listener, items: make(map[string][]byte)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fm.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return fm
+}
+
+func (fm *fakeMemcached) keys() int {
+	fm.mutex.Lock()
+	defer fm.mutex.Unlock()
+	return len(fm.items)
+}
+
+func (fm *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		var value []byte
+		if (args[0] == "set" || args[0] == "add") && len(args) == 5 {
+			size, _ := strconv.Atoi(args[4])
+			value = make([]byte, size+2)
+			if _, err := io.ReadFull(r, value); err != nil {
+				return
+			}
+			value = value[:size]
+		}
+		fm.mutex.Lock()
+		drop := fm.dropNext
+		fm.dropNext = false
+		reply := fm.reply(args, value)
+		fm.mutex.Unlock()
+		if drop {
+			return
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (fm *fakeMemcached) reply(args []string, value []byte) string {
+	if len(args) < 2 && args[0] != "version" {
+		return "ERROR\r\n"
+	}
+	switch args[0] {
+	case "version":
+		return "VERSION 1.6.0-fake\r\n"
+	case "get":
+		if v, ok := fm.items[args[1]]; ok {
+			return fmt.Sprintf("VALUE %s 0 %d\r\n%s\r\nEND\r\n", args[1], len(v), v)
+		}
+		return "END\r\n"
+	case "set":
+		fm.items[args[1]] = value
+		return "STORED\r\n"
+	case "add":
+		if _, ok := fm.items[args[1]]; ok {
+			return "NOT_STORED\r\n"
+		}
+		fm.items[args[1]] = value
+		return "STORED\r\n"
+	case "incr":
+		v, ok := fm.items[args[1]]
+		if !ok {
+			return "NOT_FOUND\r\n"
+		}
+		n, err := strconv.ParseUint(string(v), 10, 64)
+		if err != nil {
+			return "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"
+		}
+		fm.items[args[1]] = []byte(strconv.FormatUint(n+1, 10))
+		return fmt.Sprintf("%d\r\n", n+1)
+	case "delete":
+		if _, ok := fm.items[args[1]]; !ok {
+			return "NOT_FOUND\r\n"
+		}
+		delete(fm.items, args[1])
+		return "DELETED\r\n"
+	}
+	return "ERROR\r\n"
+}
+
+func TestMemcachedDriver(t *testing.T) {
+	fm := startFakeMemcached(t)
+	driver := NewMemcachedDriver(addrConfig(t, fm.listener.Addr().String()))
+	defer driver.Close()
+	conn, _ := driver.Dial()
+	defer conn.Close()
+
+	version, err := conn.(versioner).ServerVersion()
+	if err != nil || version != "1.6.0-fake" {
+		t.Errorf("ServerVersion = %q, %v", version, err)
+	}
+	replies := conn.Pipeline([]*Command{
+		{Name: "SET", Args: []interface{}{"k", []byte("v\r\nv")}},
+		{Name: "GET", Args: []interface{}{"k"}},
+		{Name: "GET", Args: []interface{}{"missing"}},
+		{Name: "INCR", Args: []interface{}{"ctr"}},
+		{Name: "INCR", Args: []interface{}{"ctr"}},
+		{Name: "INCR", Args: []interface{}{"k"}},
+		{Name: "PING"},
+		{Name: "DEL", Args: []interface{}{"k"}},
+	})
+	want := []interface{}{"STORED", []byte("v\r\nv"), nil, int64(1), int64(2), nil,
+		"1.6.0-fake", "DELETED"}
+	for i, r := range replies {
+		if i == 5 {
+			// k holds no number
+			if _, ok := r.Err.(MemcachedError); !ok {
+				t.Errorf("INCR of a string replied %v, %v, want a client error", r.Value, r.Err)
+			}
+			continue
+		}
+		if r.Err != nil || !reflect.DeepEqual(r.Value, want[i]) {
+			t.Errorf("Reply %d = %#v, %v, want %#v", i, r.Value, r.Err, want[i])
+		}
+	}
+
+	// the connection reconnects after it is dropped
+	fm.mutex.Lock()
+	fm.dropNext = true
+	fm.mutex.Unlock()
+	if _, err := conn.Execute(&Command{Name: "PING"}); err == nil {
+		t.Error("PING on a dropped connection did not fail")
+	}
+	if v, err := conn.Execute(&Command{Name: "GET", Args: []interface{}{"ctr"}}); err != nil ||
+		string(v.([]byte)) != "2" {
+		t.Errorf("GET after reconnecting = %v, %v, want 2", v, err)
+	}
+}
+
+func TestRunnerAgainstFakeMemcached(t *testing.T) {
+	fm := startFakeMemcached(t)
+	conf := addrConfig(t, fm.listener.Addr().String())
+	conf.Driver = "memcached"
+	conf.Tests = memcachedTests
+	conf.Pipeline = 4
+
+	report, err := NewRunner(conf).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Metadata.ServerVersion != "1.6.0-fake" || len(report.Results) != 4 {
+		t.Fatalf("Run against %s reported %d results", report.Metadata.ServerVersion,
+			len(report.Results))
+	}
+	for _, br := range report.Results {
+		if br.ErrorCount != 0 || br.QPS <= 0 {
+			t.Errorf("%s has %d errors %v and %v QPS", br.BenchTestName, br.ErrorCount,
+				br.Errors, br.QPS)
+		}
+	}
+	if keys := fm.keys(); keys != 0 {
+		t.Errorf("%d keys are left behind in memcached", keys)
+	}
+}
@@ -0,0 +1,82 @@
+package benchdis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+)
+
+// SupportedPayloads lists the payload content modes understood by --payload. A payload can
+// also be read from a file with the "file:<path>" form.
+var SupportedPayloads []string = []string{"random", "zeros", "text", "json"}
+
+const filePayloadPrefix = "file:"
+
+var payloadWords = []string{"lorem", "ipsum", "dolor", "sit", "amet", "consectetur",
+	"adipiscing", "elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore", "et",
+	"dolore", "magna", "aliqua", "enim", "ad", "minim", "veniam", "quis", "nostrud"}
+
+// validPayload checks whether the payload mode is one of the supported modes or a file payload
+func validPayload(mode string) bool {
+	if strings.HasPrefix(mode, filePayloadPrefix) {
+		return len(mode) > len(filePayloadPrefix)
+	}
+	return searchInList(mode, SupportedPayloads)
+}
+
+// generatePayload creates the value of exactly size bytes that is sent with every request
+// carrying data. random data is incompressible, zeros is trivially compressible while text
+// and json mimic realistic content.
+func generatePayload(mode string, size int) ([]byte, error) {
+	switch {
+	case mode == "random":
+		data := make([]byte, size)
+		rand.Read(data)
+		return data, nil
+	case mode == "zeros":
+		return make([]byte, size), nil
+	case mode == "text":
+		return fillPayload(size, func(i int) string {
+			return payloadWords[rand.Intn(len(payloadWords))] + " "
+		}), nil
+	case mode == "json":
+		data := fillPayload(size, func(i int) string {
+			sep := ","
+			if i == 0 {
+				sep = "["
+			}
+			return fmt.Sprintf(`%s{"id":%d,"user":"%s","active":%t,"score":%0.2f}`, sep,
+				rand.Intn(100000), payloadWords[rand.Intn(len(payloadWords))],
+				rand.Intn(2) == 1, rand.Float64()*100)
+		})
+		if size > 0 {
+			data[size-1] = ']'
+		}
+		return data, nil
+	case strings.HasPrefix(mode, filePayloadPrefix):
+		content, err := ioutil.ReadFile(strings.TrimPrefix(mode, filePayloadPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read payload file: %s", err.Error())
+		}
+		if len(content) == 0 {
+			return nil, fmt.Errorf("Payload file %s is empty", mode)
+		}
+		return fillPayload(size, func(i int) string {
+			return string(content)
+		}), nil
+	default:
+		return nil, fmt.Errorf("Payload %s is not supported", mode)
+	}
+}
+
+// fillPayload repeatedly appends the chunks generated by next till size bytes are
+// available and truncates the result to size.
+func fillPayload(size int, next func(i int) string) []byte {
+	builder := strings.Builder{}
+	builder.Grow(size)
+	for i := 0; builder.Len() < size; i++ {
+		builder.WriteString(next(i))
+	}
+	return []byte(builder.String()[:size])
+}
@@ -0,0 +1,99 @@
+package benchdis
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// percentileKey is the key under which a percentile is stored in BenchmarkResult, like p99
+// or p99.9
+func percentileKey(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// percentileValue parses a percentile key back into the percentile
+func percentileValue(key string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimPrefix(key, "p"), 64)
+}
+
+// percentileKeys returns the union of the percentile keys of the results, sorted by the
+// percentile value
+func percentileKeys(brs []*BenchmarkResult) []string {
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, br := range brs {
+		for k := range br.Percentiles {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, _ := percentileValue(keys[i])
+		pj, _ := percentileValue(keys[j])
+		return pi < pj
+	})
+	return keys
+}
+
+// percentileHeader is the column header used for a percentile key in the reports
+func percentileHeader(key string) string {
+	return strings.ToUpper(key)
+}
+
+// legacyLatencies are the latency percentiles of the results saved before the percentiles
+// became configurable, when they were fields of their own
+type legacyLatencies struct {
+	Median float64 `json:"median" yaml:"median"`
+	P75    float64 `json:"p75" yaml:"p75"`
+	P90    float64 `json:"p90" yaml:"p90"`
+	P99    float64 `json:"p99" yaml:"p99"`
+}
+
+// merge adds the legacy percentiles to the percentiles of the result, unless it has them
+func (ll legacyLatencies) merge(br *BenchmarkResult) {
+	old := map[float64]float64{50: ll.Median, 75: ll.P75, 90: ll.P90, 99: ll.P99}
+	for p, v := range old {
+		k := percentileKey(p)
+		if _, ok := br.Percentiles[k]; ok || v == 0 {
+			continue
+		}
+		if br.Percentiles == nil {
+			br.Percentiles = make(map[string]float64)
+		}
+		br.Percentiles[k] = v
+	}
+}
+
+// UnmarshalJSON reads a result along with the percentiles of the results saved by older
+// versions, so that they can still be compared against
+func (br *BenchmarkResult) UnmarshalJSON(data []byte) error {
+	type plain BenchmarkResult
+	if err := json.Unmarshal(data, (*plain)(br)); err != nil {
+		return err
+	}
+	var ll legacyLatencies
+	if err := json.Unmarshal(data, &ll); err != nil {
+		return err
+	}
+	ll.merge(br)
+	return nil
+}
+
+// UnmarshalYAML reads a result along with the percentiles of the results saved by older
+// versions, as UnmarshalJSON does
+func (br *BenchmarkResult) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain BenchmarkResult
+	if err := unmarshal((*plain)(br)); err != nil {
+		return err
+	}
+	var ll legacyLatencies
+	if err := unmarshal(&ll); err != nil {
+		return err
+	}
+	ll.merge(br)
+	return nil
+}
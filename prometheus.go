@@ -0,0 +1,208 @@
+package benchdis
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const promContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// promBuckets are the upper bounds in seconds of the request duration histogram
+var promBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5, 1}
+
+// testMetrics are the live metrics of one benchmark test. Requests and the histogram are
+// updated atomically from every client, errors are rare enough to be guarded by a mutex.
+type testMetrics struct {
+	requests  uint64
+	sumMicros uint64
+	buckets   []uint64
+	errMutex  sync.Mutex
+	errors    map[string]uint64
+}
+
+// PromRegistry holds the live metrics of all the tests of a run and renders them in the
+// Prometheus text exposition format
+type PromRegistry struct {
+	tests  []string
+	byTest map[string]*testMetrics
+}
+
+// NewPromRegistry creates the registry with empty metrics for all the tests
+func NewPromRegistry(tests []string) *PromRegistry {
+	reg := PromRegistry{
+		tests:  tests,
+		byTest: make(map[string]*testMetrics, len(tests)),
+	}
+	for _, t := range tests {
+		reg.byTest[t] = &testMetrics{
+			buckets: make([]uint64, len(promBuckets)),
+			errors:  make(map[string]uint64),
+		}
+	}
+	return &reg
+}
+
+// Attach makes the benchmarks report every request to the registry
+func (reg *PromRegistry) Attach(benchmarks *Benchmarks) {
+	for _, b := range benchmarks.All() {
+		b.promMetrics = reg.byTest[b.BenchTestName]
+	}
+}
+
+func (tm *testMetrics) observe(latency time.Duration) {
+	atomic.AddUint64(&tm.requests, 1)
+	atomic.AddUint64(&tm.sumMicros, uint64(latency.Microseconds()))
+	secs := latency.Seconds()
+	for i, le := range promBuckets {
+		if secs <= le {
+			atomic.AddUint64(&tm.buckets[i], 1)
+			break
+		}
+	}
+}
+
+func (tm *testMetrics) observeError(category string) {
+	tm.errMutex.Lock()
+	defer tm.errMutex.Unlock()
+	tm.errors[category]++
+}
+
+// WriteTo writes the current value of all the metrics in the text exposition format
+func (reg *PromRegistry) WriteTo(w io.Writer) (int64, error) {
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("# HELP benchdis_requests_total Requests completed successfully.\n")
+	buffer.WriteString("# TYPE benchdis_requests_total counter\n")
+	for _, t := range reg.tests {
+		fmt.Fprintf(buffer, "benchdis_requests_total{test=%s} %d\n", promLabel(t),
+			atomic.LoadUint64(&reg.byTest[t].requests))
+	}
+
+	buffer.WriteString("# HELP benchdis_errors_total Requests failed, by error type.\n")
+	buffer.WriteString("# TYPE benchdis_errors_total counter\n")
+	for _, t := range reg.tests {
+		tm := reg.byTest[t]
+		tm.errMutex.Lock()
+		cats := make([]string, 0, len(tm.errors))
+		for c := range tm.errors {
+			cats = append(cats, c)
+		}
+		sort.Strings(cats)
+		for _, c := range cats {
+			fmt.Fprintf(buffer, "benchdis_errors_total{test=%s,type=%s} %d\n", promLabel(t),
+				promLabel(c), tm.errors[c])
+		}
+		tm.errMutex.Unlock()
+	}
+
+	buffer.WriteString("# HELP benchdis_request_duration_seconds Latency of successful requests.\n")
+	buffer.WriteString("# TYPE benchdis_request_duration_seconds histogram\n")
+	for _, t := range reg.tests {
+		tm := reg.byTest[t]
+		cumulative := uint64(0)
+		for i, le := range promBuckets {
+			cumulative += atomic.LoadUint64(&tm.buckets[i])
+			fmt.Fprintf(buffer, "benchdis_request_duration_seconds_bucket{test=%s,le=\"%g\"} %d\n",
+				promLabel(t), le, cumulative)
+		}
+		count := atomic.LoadUint64(&tm.requests)
+		fmt.Fprintf(buffer, "benchdis_request_duration_seconds_bucket{test=%s,le=\"+Inf\"} %d\n",
+			promLabel(t), count)
+		fmt.Fprintf(buffer, "benchdis_request_duration_seconds_sum{test=%s} %g\n", promLabel(t),
+			float64(atomic.LoadUint64(&tm.sumMicros))/1e6)
+		fmt.Fprintf(buffer, "benchdis_request_duration_seconds_count{test=%s} %d\n",
+			promLabel(t), count)
+	}
+	n, err := w.Write(buffer.Bytes())
+	return int64(n), err
+}
+
+// ServeMetrics serves the live metrics on /metrics at the given address in the background,
+// until the returned server is closed
+func (reg *PromRegistry) ServeMetrics(addr string, logger *Logger) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot serve prometheus metrics on %s: %s", addr, err.Error())
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", promContentType)
+		reg.WriteTo(w)
+	})
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("Cannot serve prometheus metrics on %s: %s", addr, err.Error())
+		}
+	}()
+	return server, nil
+}
+
+// writeResultMetrics writes the final results of the report as gauges in the text
+// exposition format
+func writeResultMetrics(w io.Writer, rpt *Report) {
+	fmt.Fprintln(w, "# HELP benchdis_qps Throughput of the test in requests per second.")
+	fmt.Fprintln(w, "# TYPE benchdis_qps gauge")
+	for _, br := range rpt.Results {
+		fmt.Fprintf(w, "benchdis_qps{%s} %g\n", resultLabels(br), br.QPS)
+	}
+	fmt.Fprintln(w, "# HELP benchdis_latency_seconds Latency of the test by percentile.")
+	fmt.Fprintln(w, "# TYPE benchdis_latency_seconds gauge")
+	for _, br := range rpt.Results {
+		for _, k := range percentileKeys([]*BenchmarkResult{br}) {
+			fmt.Fprintf(w, "benchdis_latency_seconds{%s,percentile=%s} %g\n",
+				resultLabels(br), promLabel(strings.TrimPrefix(k, "p")), br.Percentiles[k]/1000)
+		}
+	}
+	fmt.Fprintln(w, "# HELP benchdis_errors Failed requests of the test.")
+	fmt.Fprintln(w, "# TYPE benchdis_errors gauge")
+	for _, br := range rpt.Results {
+		fmt.Fprintf(w, "benchdis_errors{%s} %d\n", resultLabels(br), br.ErrorCount)
+	}
+}
+
+// resultLabels are the labels of the metrics of a result, the test and the sweep level, if any
+func resultLabels(br *BenchmarkResult) string {
+	labels := "test=" + promLabel(br.BenchTestName)
+	if len(br.Sweep) > 0 {
+		labels += ",sweep=" + promLabel(br.Sweep)
+	}
+	return labels
+}
+
+// PushgatewaySink pushes the final results of the report to a Prometheus Pushgateway,
+// replacing the metrics of the earlier push from the same host
+type PushgatewaySink struct {
+	URL string
+}
+
+var _ ResultSink = PushgatewaySink{}
+
+func (ps PushgatewaySink) Name() string {
+	return "pushgateway"
+}
+
+func (ps PushgatewaySink) Send(rpt *Report) error {
+	target := fmt.Sprintf("%s/metrics/job/benchdis/instance/%s",
+		strings.TrimSuffix(ps.URL, "/"), url.PathEscape(rpt.Metadata.Hostname))
+	buffer := new(bytes.Buffer)
+	writeResultMetrics(buffer, rpt)
+	return sendSink(http.MethodPut, target, promContentType, buffer.Bytes())
+}
+
+// promLabel quotes and escapes a label value
+func promLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return `"` + v + `"`
+}
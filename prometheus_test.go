@@ -0,0 +1,190 @@
+package benchdis
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sinkRequest is a request received by a stand-in for a sink
+type sinkRequest struct {
+	Method      string
+	Path        string
+	ContentType string
+	Body        string
+}
+
+// startSinkServer starts an http stand-in for a sink which records the requests and responds
+// with the status
+func startSinkServer(t *testing.T, status int) (*httptest.Server, func() []sinkRequest) {
+	t.Helper()
+	var requests []sinkRequest
+	var mutex sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, sinkRequest{r.Method, r.URL.Path,
+			r.Header.Get("Content-Type"), string(body)})
+		mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []sinkRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]sinkRequest(nil), requests...)
+	}
+}
+
+// freeAddr returns a local address with a port which is free, for servers which cannot
+// listen on port 0
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// scrape gets the metrics served at the address
+func scrape(addr string) (string, error) {
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != promContentType {
+		return "", fmt.Errorf("Metrics are served as %s", ct)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestServeMetrics(t *testing.T) {
+	reg := NewPromRegistry([]string{"get"})
+	tm := reg.byTest["get"]
+	tm.observe(300 * time.Microsecond)
+	tm.observe(2 * time.Millisecond)
+	tm.observeError("timeout")
+
+	addr := freeAddr(t)
+	server, err := reg.ServeMetrics(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := scrape(addr)
+	if err != nil {
+		t.Fatalf("Cannot scrape the metrics: %v", err)
+	}
+	for _, want := range []string{
+		`benchdis_requests_total{test="get"} 2`,
+		`benchdis_errors_total{test="get",type="timeout"} 1`,
+		`benchdis_request_duration_seconds_bucket{test="get",le="0.0005"} 1`,
+		`benchdis_request_duration_seconds_bucket{test="get",le="+Inf"} 2`,
+		`benchdis_request_duration_seconds_sum{test="get"} 0.0023`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Metrics do not have %s:\n%s", want, body)
+		}
+	}
+
+	// the address is taken until the server is closed
+	if _, err := reg.ServeMetrics(addr, nil); err == nil {
+		t.Error("Serving twice on the same address did not fail")
+	}
+	server.Close()
+	if _, err := scrape(addr); err == nil {
+		t.Error("Metrics are served after the server is closed")
+	}
+	server, err = reg.ServeMetrics(addr, nil)
+	if err != nil {
+		t.Fatalf("Cannot serve again after the server is closed: %v", err)
+	}
+	server.Close()
+}
+
+func TestPromMetricsDuringRun(t *testing.T) {
+	server := startFakeServer(t)
+	conf := testConfig(t, server)
+	conf.Tests = []string{"ping", "set"}
+	conf.NReqs = 5000
+	conf.PromListen = freeAddr(t)
+
+	// the metrics are scraped once ping is done and set is under way
+	scraped := make(chan string, 1)
+	go func() {
+		for server.Keys() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		body, err := scrape(conf.PromListen)
+		if err != nil {
+			body = err.Error()
+		}
+		scraped <- body
+	}()
+	if _, err := NewRunner(conf).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	body := <-scraped
+	if want := `benchdis_requests_total{test="ping"} 5000`; !strings.Contains(body, want) {
+		t.Errorf("Metrics during the run do not have %s:\n%s", want, body)
+	}
+	if _, err := scrape(conf.PromListen); err == nil {
+		t.Error("Metrics are served after the run")
+	}
+
+	// the next run serves its metrics on the same address
+	conf.NReqs = 200
+	if _, err := NewRunner(conf).Run(context.Background()); err != nil {
+		t.Errorf("Second run in the process failed: %v", err)
+	}
+	taken, err := net.Listen("tcp", conf.PromListen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	if _, err := NewRunner(conf).Run(context.Background()); err == nil {
+		t.Error("Run did not fail when the metrics address is taken")
+	}
+}
+
+func TestPushgatewaySink(t *testing.T) {
+	server, requests := startSinkServer(t, http.StatusOK)
+	rpt := testReport()
+	rpt.Metadata.Hostname = "bench host"
+	if err := (PushgatewaySink{URL: server.URL + "/"}).Send(rpt); err != nil {
+		t.Fatal(err)
+	}
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("Pushgateway got %d requests, want 1", len(reqs))
+	}
+	pushed := reqs[0]
+	if pushed.Method != http.MethodPut || pushed.ContentType != promContentType ||
+		pushed.Path != "/metrics/job/benchdis/instance/bench host" {
+		t.Errorf("Pushed with %s %s as %s", pushed.Method, pushed.Path, pushed.ContentType)
+	}
+	for _, want := range []string{
+		`benchdis_qps{test="set"} 12000.5`,
+		`benchdis_latency_seconds{test="set",percentile="99"} 0.0015`,
+		`benchdis_errors{test="get"} 12`,
+	} {
+		if !strings.Contains(pushed.Body, want) {
+			t.Errorf("Pushed metrics do not have %s:\n%s", want, pushed.Body)
+		}
+	}
+
+	failing, _ := startSinkServer(t, http.StatusBadGateway)
+	if err := (PushgatewaySink{URL: failing.URL}).Send(rpt); err == nil {
+		t.Error("Push answered with 502 did not fail")
+	}
+}
@@ -0,0 +1,127 @@
+package benchdis
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// redisBenchmarkHeader is the header of the CSV output of redis-benchmark 7
+var redisBenchmarkHeader = []string{"test", "rps", "avg_latency_ms", "min_latency_ms",
+	"p50_latency_ms", "p95_latency_ms", "p99_latency_ms", "max_latency_ms"}
+
+// redisBenchmarkPercentiles are the percentiles needed by the redis-benchmark CSV format
+var redisBenchmarkPercentiles = []float64{50, 95, 99}
+
+// redisBenchmarkNames maps the tests to the test names used by redis-benchmark. Tests not
+// in the map use their name in upper case.
+var redisBenchmarkNames = map[string]string{
+	"ping": "PING_MBULK",
+}
+
+// RedisBenchmarkCsvReporter reports the results in the same CSV layout as
+// redis-benchmark --csv, so that existing scripts and historical data can be reused
+type RedisBenchmarkCsvReporter struct{}
+
+var _ Reporter = RedisBenchmarkCsvReporter{}
+
+func (rr RedisBenchmarkCsvReporter) ReportResults(rpt *Report) string {
+	builder := strings.Builder{}
+	rr.writeRow(&builder, redisBenchmarkHeader)
+	for _, br := range rpt.Results {
+		// the layout has no place to flag partial results, so they are left out
+		if br.Partial {
+			continue
+		}
+		rr.writeRow(&builder, []string{
+			redisBenchmarkName(br.BenchTestName),
+			fmt.Sprintf("%0.2f", br.QPS),
+			fmt.Sprintf("%0.3f", br.AvgLatency),
+			fmt.Sprintf("%0.3f", br.MinLatency),
+			fmt.Sprintf("%0.3f", br.Percentiles["p50"]),
+			fmt.Sprintf("%0.3f", br.Percentiles["p95"]),
+			fmt.Sprintf("%0.3f", br.Percentiles["p99"]),
+			fmt.Sprintf("%0.3f", br.MaxLatency),
+		})
+	}
+	return builder.String()
+}
+
+// writeRow writes a row with every field quoted, as redis-benchmark does
+func (rr RedisBenchmarkCsvReporter) writeRow(builder *strings.Builder, fields []string) {
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = `"` + strings.ReplaceAll(f, `"`, `""`) + `"`
+	}
+	builder.WriteString(strings.Join(quoted, ",") + "\n")
+}
+
+func redisBenchmarkName(test string) string {
+	if name, ok := redisBenchmarkNames[test]; ok {
+		return name
+	}
+	return strings.ToUpper(test)
+}
+
+// benchdisTestName maps a redis-benchmark test name back to the benchdis test. Names like
+// "LPUSH (needed to benchmark LRANGE)" lose their description and PING_INLINE and
+// PING_MBULK both map to ping.
+func benchdisTestName(name string) string {
+	name = strings.TrimSpace(name)
+	if idx := strings.Index(name, " ("); idx > 0 {
+		name = name[:idx]
+	}
+	if strings.HasPrefix(name, "PING_") {
+		return "ping"
+	}
+	return strings.ToLower(name)
+}
+
+// ImportRedisBenchmarkCSV reads the CSV output of redis-benchmark into results. Both the
+// redis-benchmark 7 layout with latencies and the older two column layout with only the
+// requests per second are understood. Only the first row of a test is kept.
+func ImportRedisBenchmarkCSV(r io.Reader) ([]*BenchmarkResult, error) {
+	csvRd := csv.NewReader(r)
+	csvRd.FieldsPerRecord = -1
+	records, err := csvRd.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	brs := make([]*BenchmarkResult, 0, len(records))
+	seen := make(map[string]bool)
+	for i, rec := range records {
+		if len(rec) == 0 || (i == 0 && rec[0] == redisBenchmarkHeader[0]) {
+			continue
+		}
+		if len(rec) != 2 && len(rec) != len(redisBenchmarkHeader) {
+			return nil, fmt.Errorf("Line %d has %d fields, expected 2 or %d", i+1, len(rec),
+				len(redisBenchmarkHeader))
+		}
+		values := make([]float64, len(rec)-1)
+		for j, f := range rec[1:] {
+			values[j], err = strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Line %d has invalid value %s", i+1, f)
+			}
+		}
+		test := benchdisTestName(rec[0])
+		if seen[test] {
+			continue
+		}
+		seen[test] = true
+		br := BenchmarkResult{BenchTestName: test, QPS: values[0]}
+		if len(values) > 1 {
+			br.AvgLatency = values[1]
+			br.MinLatency = values[2]
+			br.MaxLatency = values[6]
+			br.Percentiles = make(map[string]float64, len(redisBenchmarkPercentiles))
+			for j, p := range redisBenchmarkPercentiles {
+				br.Percentiles[percentileKey(p)] = values[3+j]
+			}
+		}
+		brs = append(brs, &br)
+	}
+	return brs, nil
+}
@@ -0,0 +1,71 @@
+package benchdis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Version of benchdis, overridden at build time with
+// -ldflags "-X github.com/daichi-m/benchdis.Version=<version>"
+var Version = "dev"
+
+// RunMetadata records everything needed to reproduce a run and compare it with later runs
+type RunMetadata struct {
+	Timestamp     time.Time `json:"timestamp" yaml:"timestamp"`
+	Version       string    `json:"version" yaml:"version"`
+	Hostname      string    `json:"hostname" yaml:"hostname"`
+	GoVersion     string    `json:"go_version" yaml:"go_version"`
+	ServerVersion string    `json:"server_version,omitempty" yaml:"server_version,omitempty"`
+	Duration      float64   `json:"duration_secs" yaml:"duration_secs"`
+	Partial       bool      `json:"partial,omitempty" yaml:"partial,omitempty"`
+	Config        Config    `json:"config" yaml:"config"`
+}
+
+// Report is the envelope of the results of a run, along with its metadata, the comparison
+// against the baseline and the outcome of the SLOs, if any
+type Report struct {
+	Metadata   *RunMetadata       `json:"metadata" yaml:"metadata"`
+	Results    []*BenchmarkResult `json:"results" yaml:"results"`
+	Comparison *Comparison        `json:"comparison,omitempty" yaml:"comparison,omitempty"`
+	SLOs       []*SLOResult       `json:"slo,omitempty" yaml:"slo,omitempty"`
+	Sweep      *Sweep             `json:"sweep,omitempty" yaml:"sweep,omitempty"`
+}
+
+// NewReport creates the report for the results of a run which started at start. The auth
+// in the config is redacted.
+func NewReport(conf *Config, results []*BenchmarkResult, serverVersion string,
+	start time.Time) *Report {
+
+	hostname, _ := os.Hostname()
+	meta := RunMetadata{
+		Timestamp:     start.UTC(),
+		Version:       Version,
+		Hostname:      hostname,
+		GoVersion:     runtime.Version(),
+		ServerVersion: serverVersion,
+		Duration:      time.Since(start).Seconds(),
+		Config:        conf.Redacted(),
+	}
+	return &Report{
+		Metadata: &meta,
+		Results:  results,
+	}
+}
+
+// WriteReport renders the report in the configured output format and writes it to the
+// output file, or to stdout if no output file is configured
+func WriteReport(conf *Config, rpt *Report, logger *Logger) error {
+	out := GetReporter(conf.OutputFormat).ReportResults(rpt)
+	if len(conf.Out) == 0 {
+		fmt.Println(out)
+		return nil
+	}
+	if err := ioutil.WriteFile(conf.Out, []byte(out+"\n"), 0644); err != nil {
+		return err
+	}
+	logger.Infof("Report written to %s", conf.Out)
+	return nil
+}
@@ -1,27 +1,35 @@
-package main
+package benchdis
 
 import (
 	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/alexeyco/simpletable"
 	yaml "gopkg.in/yaml.v2"
 )
 
-// Reporter generates the report from the given slice of BenchmarkResult to a string format
+// Reporter generates the report from the given Report to a string format
 type Reporter interface {
-	ReportResults(br []*BenchmarkResult) string
+	ReportResults(rpt *Report) string
 }
 
 type JsonReporter struct {
 }
 
 var _ Reporter = JsonReporter{}
+var _ ComparisonReporter = JsonReporter{}
 
-func (jr JsonReporter) ReportResults(br []*BenchmarkResult) string {
-	j, _ := json.MarshalIndent(br, "", "  ")
+func (jr JsonReporter) ReportResults(rpt *Report) string {
+	j, _ := json.MarshalIndent(rpt, "", "  ")
+	return string(j)
+}
+
+func (jr JsonReporter) ReportComparison(c *Comparison) string {
+	j, _ := json.MarshalIndent(c, "", "  ")
 	return string(j)
 }
 
@@ -29,9 +37,15 @@ type YamlReporter struct {
 }
 
 var _ Reporter = YamlReporter{}
+var _ ComparisonReporter = YamlReporter{}
 
-func (yr YamlReporter) ReportResults(br []*BenchmarkResult) string {
-	y, _ := yaml.Marshal(br)
+func (yr YamlReporter) ReportResults(rpt *Report) string {
+	y, _ := yaml.Marshal(rpt)
+	return string(y)
+}
+
+func (yr YamlReporter) ReportComparison(c *Comparison) string {
+	y, _ := yaml.Marshal(c)
 	return string(y)
 }
 
@@ -39,28 +53,74 @@ type CsvReporter struct {
 }
 
 var _ Reporter = CsvReporter{}
+var _ ComparisonReporter = CsvReporter{}
 
-func (cr CsvReporter) ReportResults(brs []*BenchmarkResult) string {
+func (cr CsvReporter) ReportResults(rpt *Report) string {
 
-	buffer := bytes.NewBuffer(make([]byte, 100*1024))
+	brs := rpt.Results
+	buffer := new(bytes.Buffer)
 	csvWr := csv.NewWriter(buffer)
-	header := []string{"Test", "QPS", "Min", "Avg", "Median", "P75", "P90", "P99", "Max"}
+	pcts := percentileKeys(brs)
+	header := []string{"Test", "QPS", "Min", "Avg"}
+	for _, k := range pcts {
+		header = append(header, percentileHeader(k))
+	}
+	header = append(header, "Max", "Payload", "Errors", "Error Types")
+	summaries := summaryKeys(brs)
+	for _, k := range summaries {
+		h := summaryHeader(k)
+		header = append(header, h+" StdDev", h+" CI95 Low", h+" CI95 High")
+	}
 	csvWr.Write(header)
 
 	for _, br := range brs {
-		data := make([]string, 0, 9)
-		data = append(data, br.BenchTestName)
+		data := make([]string, 0, len(header))
+		data = append(data, br.Label())
 		data = append(data, cr.valueToString(br.QPS))
 		data = append(data, cr.valueToString(br.MinLatency))
 		data = append(data, cr.valueToString(br.AvgLatency))
-		data = append(data, cr.valueToString(br.MedianLatency))
-		data = append(data, cr.valueToString(br.P75Latency))
-		data = append(data, cr.valueToString(br.P90Latency))
-		data = append(data, cr.valueToString(br.P99Latency))
+		for _, k := range pcts {
+			data = append(data, cr.valueToString(br.Percentiles[k]))
+		}
 		data = append(data, cr.valueToString(br.MaxLatency))
+		data = append(data, br.Payload)
+		data = append(data, fmt.Sprintf("%d", br.ErrorCount))
+		data = append(data, cr.errorsToString(br))
+		for _, k := range summaries {
+			ms, ok := br.Summary[k]
+			if !ok {
+				data = append(data, "NA", "NA", "NA")
+				continue
+			}
+			data = append(data, fmt.Sprintf("%0.3f", ms.StdDev), fmt.Sprintf("%0.3f", ms.CILow),
+				fmt.Sprintf("%0.3f", ms.CIHigh))
+		}
 		csvWr.Write(data)
 	}
 	csvWr.Flush()
+	if rpt.Comparison != nil {
+		return string(buffer.Bytes()) + "\n" + cr.ReportComparison(rpt.Comparison)
+	}
+	return string(buffer.Bytes())
+}
+
+func (cr CsvReporter) ReportComparison(c *Comparison) string {
+
+	buffer := new(bytes.Buffer)
+	csvWr := csv.NewWriter(buffer)
+	csvWr.Write([]string{"Test", "Metric", "Baseline", "Current", "Change %", "Gated",
+		"Regression"})
+	for _, tc := range c.Tests {
+		for _, d := range tc.Deltas {
+			csvWr.Write([]string{tc.Test, d.Metric, cr.valueToString(d.Old),
+				cr.valueToString(d.New), fmt.Sprintf("%0.2f", d.Change),
+				fmt.Sprintf("%t", d.Gated), fmt.Sprintf("%t", d.Regression)})
+		}
+	}
+	for _, test := range c.Missing {
+		csvWr.Write([]string{test, "missing", "", "", "", "true", "true"})
+	}
+	csvWr.Flush()
 	return string(buffer.Bytes())
 }
 
@@ -71,15 +131,35 @@ func (cr CsvReporter) valueToString(f float64) string {
 	return fmt.Sprintf("%0.3f", f)
 }
 
+func (cr CsvReporter) errorsToString(br *BenchmarkResult) string {
+	errs := make([]string, 0, len(br.Errors))
+	for _, cat := range errorCategories(br) {
+		es := br.Errors[cat]
+		errs = append(errs, fmt.Sprintf("%s=%d (%s)", cat, es.Count, es.Sample))
+	}
+	return strings.Join(errs, "; ")
+}
+
 type TableReporter struct{}
 
 var _ Reporter = TableReporter{}
+var _ ComparisonReporter = TableReporter{}
 
-func (tr TableReporter) ReportResults(brs []*BenchmarkResult) string {
+func (tr TableReporter) ReportResults(rpt *Report) string {
 
+	brs := rpt.Results
 	table := simpletable.New()
 
-	hdrStr := []string{"Test", "QPS", "Min", "Avg", "Median", "P75", "P90", "P99", "Max"}
+	pcts := percentileKeys(brs)
+	hdrStr := []string{"Test", "QPS", "Min", "Avg"}
+	for _, k := range pcts {
+		hdrStr = append(hdrStr, percentileHeader(k))
+	}
+	hdrStr = append(hdrStr, "Max", "Errors")
+	trend := hasTimeSeries(brs)
+	if trend {
+		hdrStr = append(hdrStr, "QPS Trend")
+	}
 	header := make([]*simpletable.Cell, len(hdrStr))
 	for i, h := range hdrStr {
 		header[i] = &simpletable.Cell{Text: h}
@@ -88,18 +168,144 @@ func (tr TableReporter) ReportResults(brs []*BenchmarkResult) string {
 		Cells: header,
 	}
 	for _, br := range brs {
-		row := make([]*simpletable.Cell, 0, 9)
-		row = append(row, &simpletable.Cell{Text: br.BenchTestName})
+		row := make([]*simpletable.Cell, 0, len(hdrStr))
+		row = append(row, &simpletable.Cell{Text: br.Label()})
 		row = append(row, tr.valueToCell(br.QPS))
 		row = append(row, tr.valueToCell(br.MinLatency))
 		row = append(row, tr.valueToCell(br.AvgLatency))
-		row = append(row, tr.valueToCell(br.MedianLatency))
-		row = append(row, tr.valueToCell(br.P75Latency))
-		row = append(row, tr.valueToCell(br.P90Latency))
-		row = append(row, tr.valueToCell(br.P99Latency))
+		for _, k := range pcts {
+			row = append(row, tr.valueToCell(br.Percentiles[k]))
+		}
 		row = append(row, tr.valueToCell(br.MaxLatency))
+		row = append(row, &simpletable.Cell{Text: fmt.Sprintf("%d", br.ErrorCount),
+			Align: simpletable.AlignRight})
+		if trend {
+			row = append(row, &simpletable.Cell{Text: sparkline(br.TimeSeries)})
+		}
 		table.Body.Cells = append(table.Body.Cells, row)
 	}
+	if len(brs) > 0 && len(brs[0].Payload) > 0 {
+		table.Footer = &simpletable.Footer{
+			Cells: []*simpletable.Cell{
+				{Span: len(hdrStr), Text: fmt.Sprintf("Payload: %s", brs[0].Payload)},
+			},
+		}
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	report := table.String() + tr.summaryTable(brs) + tr.errorTable(brs)
+	if rpt.Sweep != nil {
+		report = report + "\n" + tr.sweepTable(rpt.Sweep)
+	}
+	if rpt.Comparison != nil {
+		report = report + "\n" + tr.ReportComparison(rpt.Comparison)
+	}
+	return report
+}
+
+// summaryTable renders the variance of QPS and latency percentiles over repeated runs, if
+// the tests were run more than once
+func (tr TableReporter) summaryTable(brs []*BenchmarkResult) string {
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{{Text: "Test"}, {Text: "Metric"}, {Text: "Runs"},
+			{Text: "Mean"}, {Text: "StdDev"}, {Text: "95% CI"}, {Text: "Outlier Runs"}},
+	}
+	for _, br := range brs {
+		for _, k := range summaryKeys([]*BenchmarkResult{br}) {
+			ms := br.Summary[k]
+			outliers := make([]string, 0, len(ms.OutlierRuns))
+			for _, r := range ms.OutlierRuns {
+				outliers = append(outliers, fmt.Sprintf("#%d", r))
+			}
+			table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+				{Text: br.Label()},
+				{Text: summaryHeader(k)},
+				{Text: fmt.Sprintf("%d", len(br.Runs)), Align: simpletable.AlignRight},
+				{Text: fmt.Sprintf("%0.3f", ms.Mean), Align: simpletable.AlignRight},
+				{Text: fmt.Sprintf("%0.3f", ms.StdDev), Align: simpletable.AlignRight},
+				{Text: fmt.Sprintf("%0.3f - %0.3f", ms.CILow, ms.CIHigh),
+					Align: simpletable.AlignRight},
+				{Text: strings.Join(outliers, ", ")},
+			})
+		}
+	}
+	if len(table.Body.Cells) == 0 {
+		return ""
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	return "\n" + table.String()
+}
+
+// errorTable renders the per category breakdown of the errors, if any errors were seen
+func (tr TableReporter) errorTable(brs []*BenchmarkResult) string {
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{{Text: "Test"}, {Text: "Error Type"}, {Text: "Count"},
+			{Text: "Sample"}},
+	}
+	for _, br := range brs {
+		for _, cat := range errorCategories(br) {
+			es := br.Errors[cat]
+			table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+				{Text: br.Label()},
+				{Text: cat},
+				{Text: fmt.Sprintf("%d", es.Count), Align: simpletable.AlignRight},
+				{Text: es.Sample},
+			})
+		}
+	}
+	if len(table.Body.Cells) == 0 {
+		return ""
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	return "\n" + table.String()
+}
+
+func (tr TableReporter) ReportComparison(c *Comparison) string {
+
+	table := simpletable.New()
+	hdrStr := []string{"Test", "Metric", "Baseline", "Current", "Change", "Status"}
+	header := make([]*simpletable.Cell, len(hdrStr))
+	for i, h := range hdrStr {
+		header[i] = &simpletable.Cell{Text: h}
+	}
+	table.Header = &simpletable.Header{
+		Cells: header,
+	}
+	for _, tc := range c.Tests {
+		for _, d := range tc.Deltas {
+			status := ""
+			if d.Regression {
+				status = "✗ REGRESSION"
+			} else if d.Gated {
+				status = "✓"
+			}
+			table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+				{Text: tc.Test},
+				{Text: summaryHeader(d.Metric)},
+				tr.valueToCell(d.Old),
+				tr.valueToCell(d.New),
+				{Text: fmt.Sprintf("%+0.2f%%", d.Change), Align: simpletable.AlignRight},
+				{Text: status},
+			})
+		}
+	}
+	for _, test := range c.Missing {
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: test}, {Text: ""}, {Text: ""}, {Text: "NA"}, {Text: ""},
+			{Text: "✗ MISSING"},
+		})
+	}
+	verdict := "PASS"
+	if c.Regressed {
+		verdict = "FAIL"
+	}
+	table.Footer = &simpletable.Footer{
+		Cells: []*simpletable.Cell{
+			{Span: len(hdrStr),
+				Text: fmt.Sprintf("Max regression: %0.2f%%, Result: %s", c.MaxRegression, verdict)},
+		},
+	}
 	table.SetStyle(simpletable.StyleUnicode)
 	return table.String()
 }
@@ -111,6 +317,15 @@ func (tr TableReporter) valueToCell(f float64) *simpletable.Cell {
 	return &simpletable.Cell{Text: fmt.Sprintf("%0.3f", f), Align: simpletable.AlignRight}
 }
 
+func hasTimeSeries(brs []*BenchmarkResult) bool {
+	for _, br := range brs {
+		if len(br.TimeSeries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func getResults(benchmarks []*Benchmark) []*BenchmarkResult {
 	br := make([]*BenchmarkResult, 0, len(benchmarks))
 	for _, b := range benchmarks {
@@ -119,7 +334,34 @@ func getResults(benchmarks []*Benchmark) []*BenchmarkResult {
 	return br
 }
 
-func getReporter(format string) Reporter {
+// GetComparisonReporter returns the reporter for a comparison in the given format, falling
+// back to a table for formats which cannot render comparisons
+func GetComparisonReporter(format string) ComparisonReporter {
+	if cr, ok := GetReporter(format).(ComparisonReporter); ok {
+		return cr
+	}
+	return TableReporter{}
+}
+
+// sortResults sorts the results in place by the sort key, name sorts by the test name, qps
+// from the highest QPS and a percentile like p99 from the lowest latency. tests keeps the
+// order of the tests.
+func sortResults(brs []*BenchmarkResult, by string) {
+	var less func(a, b *BenchmarkResult) bool
+	switch by {
+	case "", "tests":
+		return
+	case "name":
+		less = func(a, b *BenchmarkResult) bool { return a.BenchTestName < b.BenchTestName }
+	case "qps":
+		less = func(a, b *BenchmarkResult) bool { return a.QPS > b.QPS }
+	default:
+		less = func(a, b *BenchmarkResult) bool { return a.Percentiles[by] < b.Percentiles[by] }
+	}
+	sort.SliceStable(brs, func(i, j int) bool { return less(brs[i], brs[j]) })
+}
+
+func GetReporter(format string) Reporter {
 	switch format {
 	case "json":
 		return JsonReporter{}
@@ -129,6 +371,16 @@ func getReporter(format string) Reporter {
 		return CsvReporter{}
 	case "table":
 		return TableReporter{}
+	case "markdown":
+		return MarkdownReporter{}
+	case "html":
+		return HtmlReporter{}
+	case "redis-benchmark-csv":
+		return RedisBenchmarkCsvReporter{}
+	case "gobench":
+		return GoBenchReporter{}
+	case "junit":
+		return JUnitReporter{}
 	default:
 		return TableReporter{}
 	}
@@ -0,0 +1,209 @@
+package benchdis
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// testReport is a report with a complete result, a partial result with errors, an SLO and a
+// comparison, to exercise every part of the reports
+func testReport() *Report {
+	conf := DefaultConfig()
+	conf.NReqs = 1000
+	conf.Tests = []string{"set", "get"}
+	results := []*BenchmarkResult{
+		{
+			BenchTestName: "set",
+			Payload:       "random",
+			QPS:           12000.5,
+			MinLatency:    0.1,
+			AvgLatency:    0.4,
+			MaxLatency:    3.2,
+			Percentiles:   map[string]float64{"p50": 0.3, "p95": 0.9, "p99": 1.5},
+			Histogram:     buildHistogram([]float64{0.1, 0.3, 0.4, 3.2}),
+			TimeSeries:    []*IntervalStat{{Offset: 0, QPS: 11000}, {Offset: 1, QPS: 13000}},
+		},
+		{
+			BenchTestName: "get",
+			Partial:       true,
+			Payload:       "random",
+			QPS:           15000,
+			MinLatency:    0.1,
+			AvgLatency:    0.3,
+			MaxLatency:    2.1,
+			ErrorCount:    12,
+			Percentiles:   map[string]float64{"p50": 0.2, "p95": 0.7, "p99": 1.1},
+			Errors: map[string]*ErrorStat{
+				"readonly": {Count: 12, Sample: "READONLY You can't write against a read only"},
+			},
+		},
+	}
+	rpt := NewReport(&conf, results, FakeServerVersion, time.Now())
+	slo, err := ParseSLO("set.p99 < 1ms")
+	if err != nil {
+		panic(err)
+	}
+	rpt.SLOs = EvaluateSLOs([]*SLO{slo}, results, conf.NReqs)
+	baseline := []*BenchmarkResult{
+		{BenchTestName: "set", QPS: 20000, Percentiles: map[string]float64{"p99": 1}},
+	}
+	rpt.Comparison = CompareResults(baseline, results, 10, []string{"qps", "p99"})
+	return rpt
+}
+
+func TestReporters(t *testing.T) {
+	rpt := testReport()
+	for _, format := range SupportedFormats {
+		out := GetReporter(format).ReportResults(rpt)
+		if len(strings.TrimSpace(out)) == 0 {
+			t.Errorf("%s report is empty", format)
+		}
+	}
+
+	// the structured formats are parsed back in TestStructuredReporters
+	wants := map[string][]string{
+		"table": {"║ set           │ 12000.500 │ 0.100 │ 0.400 │ 0.300 │ 0.900 │ 1.500 │",
+			"║ get (partial) │ 15000.000 │", "│     12 │", "│ READONLY You can't write",
+			"│ QPS    │ 20000.000 │ 12000.500 │ -40.00% │ ✗ REGRESSION ║"},
+		"markdown": {"| set | **12000.500 (-40.0%)** ❌ | 0.100 | 0.400 |",
+			"| get (partial) | 15000.000 | 0.100 | 0.300 |", "| 2.100 | 12 |"},
+		"html": {"<tr><td>set</td><td>12000.500</td>",
+			"<tr><td>get (partial)</td><td>15000.000</td>",
+			"<td>20000.000</td><td>12000.500</td>"},
+		"gobench": {"BenchmarkRedisSET-1", "1.500 p99-ms", "12000.5 qps"},
+	}
+	for format, want := range wants {
+		out := GetReporter(format).ReportResults(rpt)
+		for _, w := range want {
+			if !strings.Contains(out, w) {
+				t.Errorf("%s report does not have %q:\n%s", format, w, out)
+			}
+		}
+	}
+	// go test output cannot show partial results
+	if out := GetReporter("gobench").ReportResults(rpt); strings.Contains(out, "GET") {
+		t.Errorf("gobench report has the partial get:\n%s", out)
+	}
+}
+
+func TestStructuredReporters(t *testing.T) {
+	rpt := testReport()
+
+	var fromJSON Report
+	err := json.Unmarshal([]byte(JsonReporter{}.ReportResults(rpt)), &fromJSON)
+	if err != nil {
+		t.Errorf("json report does not parse: %v", err)
+	} else if len(fromJSON.Results) != 2 || fromJSON.Results[1].ErrorCount != 12 {
+		t.Errorf("json report has results %v", fromJSON.Results)
+	}
+
+	var fromYAML Report
+	err = yaml.Unmarshal([]byte(YamlReporter{}.ReportResults(rpt)), &fromYAML)
+	if err != nil {
+		t.Errorf("yaml report does not parse: %v", err)
+	} else if len(fromYAML.Results) != 2 || fromYAML.Results[0].QPS != 12000.5 {
+		t.Errorf("yaml report has results %v", fromYAML.Results)
+	}
+
+	// the comparison follows the results as a table of its own
+	r := csv.NewReader(strings.NewReader(CsvReporter{}.ReportResults(rpt)))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Errorf("csv report does not parse: %v", err)
+	} else if len(rows) != 6 || rows[0][0] != "Test" || rows[2][0] != "get (partial)" ||
+		rows[3][1] != "Metric" {
+		t.Errorf("csv report has rows %v", rows)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal([]byte(JUnitReporter{}.ReportResults(rpt)), &suites); err != nil {
+		t.Errorf("junit report does not parse: %v", err)
+	} else if suites.Tests != 2 || suites.Failures != 1 || suites.Errors != 1 {
+		t.Errorf("junit report has %d tests, %d failures and %d errors, want 2, 1 and 1",
+			suites.Tests, suites.Failures, suites.Errors)
+	}
+}
+
+func TestReportsLoadBack(t *testing.T) {
+	rpt := testReport()
+	dir := t.TempDir()
+	files := map[string]string{"json": "report.json", "yaml": "report.yaml",
+		"csv": "report.csv", "redis-benchmark-csv": "redis-benchmark.csv"}
+	for format, file := range files {
+		path := filepath.Join(dir, file)
+		if err := ioutil.WriteFile(path, []byte(GetReporter(format).ReportResults(rpt)),
+			0644); err != nil {
+			t.Fatal(err)
+		}
+		results, err := LoadResults(path)
+		if err != nil {
+			t.Errorf("Cannot load %s report: %v", format, err)
+			continue
+		}
+		if len(results) == 0 || results[0].BenchTestName != "set" ||
+			results[0].QPS != 12000.5 {
+			t.Errorf("%s report loads back as %v", format, results)
+		}
+	}
+
+	// the csv of benchdis keeps the partial results and their errors
+	results, err := LoadResults(filepath.Join(dir, "report.csv"))
+	if err != nil || len(results) != 2 || !results[1].Partial ||
+		results[1].ErrorCount != 12 || results[0].Percentiles["p95"] != 0.9 {
+		t.Errorf("csv report loads back as %v, %v", results, err)
+	}
+}
+
+func TestComparisonReporters(t *testing.T) {
+	cmp := testReport().Comparison
+	if !cmp.Regressed {
+		t.Error("QPS dropping from 20000 to 12000 is not a regression")
+	}
+	for _, format := range SupportedFormats {
+		out := GetComparisonReporter(format).ReportComparison(cmp)
+		if !strings.Contains(out, "set") {
+			t.Errorf("%s comparison does not have the set test:\n%s", format, out)
+		}
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	tests := map[string]string{"tests": "set", "name": "get", "qps": "get", "p99": "get"}
+	for by, first := range tests {
+		results := testReport().Results
+		sortResults(results, by)
+		if results[0].BenchTestName != first {
+			t.Errorf("Sorted by %s %s comes first, want %s", by, results[0].BenchTestName,
+				first)
+		}
+	}
+}
+
+func TestCompareMissingTests(t *testing.T) {
+	baseline := []*BenchmarkResult{
+		{BenchTestName: "set", QPS: 1000},
+		{BenchTestName: "get", QPS: 1000},
+	}
+	current := []*BenchmarkResult{{BenchTestName: "set", QPS: 1000}}
+	cmp := CompareResults(baseline, current, 10, []string{"qps"})
+	if !reflect.DeepEqual(cmp.Missing, []string{"get"}) || !cmp.Regressed {
+		t.Errorf("Comparison without get has missing %v and regressed %t", cmp.Missing,
+			cmp.Regressed)
+	}
+	for _, format := range []string{"table", "markdown", "csv"} {
+		if out := GetComparisonReporter(format).ReportComparison(cmp); !strings.Contains(
+			strings.ToLower(out), "missing") {
+			t.Errorf("%s comparison does not report get missing:\n%s", format, out)
+		}
+	}
+}
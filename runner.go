@@ -0,0 +1,278 @@
+// Package benchdis benchmarks redis with configurable tests, clients and payloads, and reports
+// the throughput, latencies and errors of every test. The benchdis command in cmd/benchdis is a
+// thin wrapper over the Runner of this package.
+package benchdis
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	progressbar "github.com/schollz/progressbar/v3"
+)
+
+// Runner runs the benchmark of a config. It is what the benchdis command runs, and can be
+// used by other Go code to run benchmarks without the command.
+type Runner struct {
+	Config *Config
+	// Logger logs the progress of the run, nil to log nothing
+	Logger *Logger
+	// Progress is where the progress bars of the tests are shown, nil to show none
+	Progress io.Writer
+}
+
+// NewRunner creates a runner for the config which logs nothing and shows no progress
+func NewRunner(conf *Config) *Runner {
+	return &Runner{Config: conf}
+}
+
+// Run runs all the tests of the config and returns the report of the results, along with the
+// comparison against the baseline and the outcome of the SLOs of the config. With a sweep the
+// tests are run at every level of the sweep, and the report has their curves. If the context
+// is done the run stops, and the report of the tests run so far is returned along with the
+// error of the context. The results of the test which was running are marked partial.
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	// the run fills in the config, like the percentiles and the tag, on a copy so that the
+	// config of the caller can be run again
+	validated := *r.Config
+	validated.Tests = append([]string(nil), r.Config.Tests...)
+	validated.Percentiles = append([]float64(nil), r.Config.Percentiles...)
+	conf := &validated
+	if _, err := conf.validateConfig(); err != nil {
+		return nil, err
+	}
+	var slos []*SLO
+	if len(conf.SLOFile) > 0 {
+		var err error
+		if slos, err = LoadSLOs(conf.SLOFile); err != nil {
+			return nil, err
+		}
+	}
+	faults, err := ParseFaultPhases(conf.Faults)
+	if err != nil {
+		return nil, err
+	}
+	// the baseline is loaded up front, so that a regression gate cannot pass without it
+	var baseline []*BenchmarkResult
+	if len(conf.Baseline) > 0 {
+		if baseline, err = LoadResults(conf.Baseline); err != nil {
+			return nil, fmt.Errorf("Cannot compare against baseline: %s", err.Error())
+		}
+	}
+	var sweep *SweepSpec
+	if len(conf.Sweep) > 0 {
+		if sweep, err = ParseSweep(conf.Sweep); err != nil {
+			return nil, err
+		}
+		// all the levels tag their keys alike, for benchdis cleanup
+		if len(conf.Tag) == 0 {
+			conf.Tag = newTag()
+		}
+	}
+	var registry *PromRegistry
+	if len(conf.PromListen) > 0 {
+		registry = NewPromRegistry(conf.Tests)
+		server, err := registry.ServeMetrics(conf.PromListen, r.Logger)
+		if err != nil {
+			return nil, err
+		}
+		defer server.Close()
+	}
+	var proxy *FaultProxy
+	if len(faults) > 0 {
+		proxy = NewFaultProxy(redisAddress(conf), faults)
+		if err := proxy.Listen("localhost:0"); err != nil {
+			return nil, fmt.Errorf("Cannot start the fault proxy: %s", err.Error())
+		}
+		defer proxy.Close()
+		r.Logger.Infof("Injecting faults through a proxy at %s", proxy.Addr())
+	}
+
+	start := time.Now()
+	var results []*BenchmarkResult
+	var serverVersion string
+	if sweep == nil {
+		if results, serverVersion, err = r.runTests(ctx, conf, "", registry, proxy); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, level := range sweep.Levels {
+			if ctx.Err() != nil {
+				break
+			}
+			r.Logger.Infof("Sweeping %s", sweep.label(level))
+			brs, version, err := r.runTests(ctx, sweep.apply(conf, level), sweep.label(level),
+				registry, proxy)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, brs...)
+			serverVersion = version
+		}
+	}
+
+	sortResults(results, conf.Sort)
+	report := NewReport(conf, results, serverVersion, start)
+	report.Metadata.Partial = ctx.Err() != nil
+	if sweep != nil {
+		report.Sweep = newSweep(sweep, conf.Tests, results)
+	}
+	if len(conf.Baseline) > 0 {
+		report.Comparison = CompareResults(baseline, results, conf.MaxRegression,
+			conf.RegressionMetrics)
+	}
+	report.SLOs = EvaluateSLOs(slos, results, conf.NReqs)
+	return report, ctx.Err()
+}
+
+// runTests runs the tests of the config through a fresh set of clients, and returns their
+// results labelled with the level of the sweep, if any, along with the server version. The
+// requests are reported live to the registry, if any, and go through the fault proxy, if any.
+func (r *Runner) runTests(ctx context.Context, conf *Config, level string,
+	registry *PromRegistry, proxy *FaultProxy) ([]*BenchmarkResult, string, error) {
+
+	benchmarks := InitializeBenchmarks(conf, conf.Tests, r.Logger)
+	if proxy != nil {
+		for _, b := range benchmarks.All() {
+			b.faults = proxy.Phases
+		}
+	}
+	if registry != nil {
+		registry.Attach(benchmarks)
+	}
+	scenarios, err := NewScenarioSetup(conf, r.Logger)
+	if err != nil {
+		return nil, "", fmt.Errorf("Cannot setup test scenarios: %s", err.Error())
+	}
+
+	clientConf := conf
+	if proxy != nil {
+		proxied := *conf
+		proxied.proxyAddr = proxy.Addr()
+		clientConf = &proxied
+	}
+	reqIdChan := make(chan int, conf.NReqs)
+	clients := CreateClients(clientConf, scenarios, r.Logger)
+	defer r.closeClients(conf, clients)
+	serverVersion := clients[0].ServerVersion()
+	r.Logger.Debugf("Keys are tagged with %s", conf.Tag)
+
+	ran := make([]*Benchmark, 0, len(conf.Tests))
+	for tc, test := range conf.Tests {
+		if ctx.Err() != nil {
+			break
+		}
+		bnchMk := benchmarks.Get(test)
+		for run := 0; run < conf.Repeat && ctx.Err() == nil; run++ {
+			if run > 0 {
+				select {
+				case <-time.After(conf.Cooldown):
+				case <-ctx.Done():
+					continue
+				}
+				bnchMk.Reset()
+			}
+			desc := fmt.Sprintf("[%d/%d] Running cases for %s", (tc + 1), len(conf.Tests),
+				strings.ToUpper(test))
+			if len(level) > 0 {
+				desc = fmt.Sprintf("%s at %s", desc, level)
+			}
+			if conf.Repeat > 1 {
+				desc = fmt.Sprintf("%s (run %d/%d)", desc, run+1, conf.Repeat)
+			}
+			r.runBenchmark(ctx, bnchMk, clients, reqIdChan, desc, proxy)
+		}
+		bnchMk.Summarize()
+		bnchMk.BenchmarkResult.Sweep = level
+		if ctx.Err() != nil {
+			bnchMk.BenchmarkResult.Partial = true
+			r.Logger.Errorf("Run of %s was interrupted, its results are partial", test)
+		}
+		ran = append(ran, bnchMk)
+	}
+	return getResults(ran), serverVersion, nil
+}
+
+// runBenchmark runs one round of requests for the test of the benchmark through all the
+// clients and records the results
+func (r *Runner) runBenchmark(ctx context.Context, bnchMk *Benchmark, clients []Client,
+	reqIdChan chan int, desc string, proxy *FaultProxy) {
+
+	conf := bnchMk.Config
+	progress := r.Progress
+	if progress == nil {
+		progress = ioutil.Discard
+	}
+	wg := new(sync.WaitGroup)
+	pb := progressbar.NewOptions(conf.NReqs,
+		progressbar.OptionSetWriter(progress),
+		progressbar.OptionShowBytes(false),
+		// progressbar.OptionShowCount(),
+		progressbar.OptionSetRenderBlankState(true),
+		progressbar.OptionSetDescription(desc),
+		progressbar.OptionSetWidth(50))
+	if proxy != nil {
+		proxy.Begin()
+		defer proxy.End()
+	}
+	bnchMk.StartBenchmark()
+	go generateReqIds(ctx, conf.NReqs, conf.NClients, reqIdChan)
+	for i := range clients {
+		wg.Add(1)
+		// the clients are shared with the cleanup, which deletes the keys they wrote
+		go clients[i].SendReqs(ctx, bnchMk, reqIdChan, wg, pb)
+	}
+	wg.Wait()
+	bnchMk.EndBenchmark()
+	bnchMk.Record(bnchMk.BenchTestName)
+	bnchMk.BenchmarkResult.Partial = ctx.Err() != nil
+	pb.Finish()
+	r.Logger.Infof(" Error: %0.2f%%", (float64(bnchMk.ErrorCount)/float64(conf.NReqs))*100)
+	for _, cat := range errorCategories(bnchMk.BenchmarkResult) {
+		r.Logger.Infof("   %s: %d", cat, bnchMk.Errors[cat].Count)
+	}
+}
+
+// closeClients deletes the keys written by the clients and closes their connections. The
+// cleanup is given up after the cleanup timeout, if any, leaving the keys for benchdis cleanup.
+func (r *Runner) closeClients(conf *Config, clients []Client) {
+	r.Logger.Infof("Cleaning up keys from redis and closing connections")
+	done := make(chan struct{})
+	go func() {
+		for _, cl := range clients {
+			cl.Close()
+		}
+		if len(clients) > 0 {
+			clients[0].driver.Close()
+		}
+		close(done)
+	}()
+	var timeout <-chan time.Time
+	if conf.CleanupTimeout > 0 {
+		timeout = time.After(conf.CleanupTimeout)
+	}
+	select {
+	case <-done:
+	case <-timeout:
+		r.Logger.Errorf("Cleanup did not finish in %v, remove the keys left behind with "+
+			"benchdis cleanup --tag %s", conf.CleanupTimeout, conf.Tag)
+	}
+}
+
+func generateReqIds(ctx context.Context, reqs, clients int, reqIdChan chan<- int) {
+	for i := 1; i <= reqs+clients; i++ {
+		id := i
+		if i > reqs {
+			id = -1
+		}
+		select {
+		case reqIdChan <- id:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package benchdis
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunnerAgainstFakeServer(t *testing.T) {
+	for _, pipeline := range []int{1, 8} {
+		server := startFakeServer(t)
+		conf := testConfig(t, server)
+		conf.Pipeline = pipeline
+		report, err := NewRunner(conf).Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run with pipeline %d failed: %v", pipeline, err)
+		}
+		if report.Metadata.ServerVersion != FakeServerVersion {
+			t.Errorf("Server version = %q, want %q", report.Metadata.ServerVersion,
+				FakeServerVersion)
+		}
+		if len(report.Results) != len(SupportedTests) {
+			t.Fatalf("Report has %d results, want one for each of the %d tests",
+				len(report.Results), len(SupportedTests))
+		}
+		for i, br := range report.Results {
+			if br.BenchTestName != SupportedTests[i] {
+				t.Errorf("Result %d is of %s, want %s", i, br.BenchTestName, SupportedTests[i])
+			}
+			if br.ErrorCount != 0 || br.QPS <= 0 || br.Partial {
+				t.Errorf("Result of %s with pipeline %d has %d errors and %v QPS",
+					br.BenchTestName, pipeline, br.ErrorCount, br.QPS)
+			}
+			for _, p := range conf.Percentiles {
+				if _, ok := br.Percentiles[percentileKey(p)]; !ok {
+					t.Errorf("Result of %s has no p%v", br.BenchTestName, p)
+				}
+			}
+		}
+		if keys := server.Keys(); keys != 0 {
+			t.Errorf("%d keys are left behind by the run with pipeline %d", keys, pipeline)
+		}
+	}
+}
+
+func TestRunnerCountsInjectedErrors(t *testing.T) {
+	server := NewFakeServer()
+	server.ErrorRate = 0.2
+	server.Errors = []string{"READONLY You can't write against a read only replica."}
+	if err := server.Listen("localhost:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	conf := testConfig(t, server)
+	conf.NReqs = 1000
+	conf.Tests = []string{"ping", "set"}
+
+	report, err := NewRunner(conf).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, br := range report.Results {
+		// 200 errors are expected, which is off by more than 100 once in millions of runs
+		if br.ErrorCount < 100 || br.ErrorCount > 300 {
+			t.Errorf("%s has %d errors, want about 20%% of %d", br.BenchTestName,
+				br.ErrorCount, conf.NReqs)
+		}
+		if es := br.Errors["readonly"]; es == nil || es.Count != br.ErrorCount {
+			t.Errorf("%s has errors %v, want all of them readonly", br.BenchTestName, br.Errors)
+		}
+	}
+}
+
+func TestRunnerCancelled(t *testing.T) {
+	server := NewFakeServer()
+	server.Latency = time.Millisecond
+	if err := server.Listen("localhost:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	conf := testConfig(t, server)
+	conf.NReqs = 20000
+	conf.Tests = []string{"set", "get"}
+
+	// cancel once set is under way, which takes 5 seconds to finish
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for server.Keys() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	report, err := NewRunner(conf).Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Cancelled run returned %v", err)
+	}
+	if report == nil || !report.Metadata.Partial || len(report.Results) != 1 ||
+		!report.Results[0].Partial {
+		t.Errorf("Cancelled run reported %v, want the partial set", report)
+	}
+}
+
+func TestRunnerCancelledInCooldown(t *testing.T) {
+	server := startFakeServer(t)
+	conf := testConfig(t, server)
+	conf.Tests = []string{"set"}
+	conf.Repeat = 3
+	conf.Cooldown = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	report, err := NewRunner(conf).Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run cancelled in the cooldown returned %v", err)
+	}
+	br := report.Results[0]
+	if !br.Partial || br.QPS <= 0 || len(br.Percentiles) == 0 {
+		t.Errorf("Run cancelled in the cooldown reported %+v, want the first run", br)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	server := startFakeServer(t)
+	conn := dialFake(t, server)
+	for _, k := range []string{"key:old:1", "ctr:old:2", "llist:old", "key:other:1"} {
+		conn.Do("SET", k, "v")
+	}
+	conf := testConfig(t, server)
+
+	keys, err := Cleanup(conf, "old", true)
+	if err != nil || len(keys) != 3 || server.Keys() != 4 {
+		t.Errorf("Dry run found %v, %v and left %d keys, want 3 keys found and 4 left", keys,
+			err, server.Keys())
+	}
+	keys, err = Cleanup(conf, "old", false)
+	if err != nil || len(keys) != 3 || server.Keys() != 1 {
+		t.Errorf("Cleanup deleted %v, %v and left %d keys, want 3 deleted and 1 left", keys,
+			err, server.Keys())
+	}
+}
+
+func TestRunnerLeavesConfigAlone(t *testing.T) {
+	server := startFakeServer(t)
+	conf := testConfig(t, server)
+	conf.Tests = []string{"set", "set"}
+	conf.Percentiles = []float64{90}
+	conf.OutputFormat = "redis-benchmark-csv"
+	for run := 0; run < 2; run++ {
+		report, err := NewRunner(conf).Run(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Results) != 1 || len(report.Metadata.Config.Tag) == 0 {
+			t.Errorf("Run %d has %d results and tag %q", run, len(report.Results),
+				report.Metadata.Config.Tag)
+		}
+	}
+	if len(conf.Tag) > 0 || len(conf.Tests) != 2 || len(conf.Percentiles) != 1 {
+		t.Errorf("Runs changed the config to tag %q, tests %v and percentiles %v", conf.Tag,
+			conf.Tests, conf.Percentiles)
+	}
+}
+
+func TestRunnerNeedsBaseline(t *testing.T) {
+	server := startFakeServer(t)
+	conf := testConfig(t, server)
+	conf.Tests = []string{"ping"}
+	conf.Baseline = filepath.Join(t.TempDir(), "missing.json")
+	if report, err := NewRunner(conf).Run(context.Background()); err == nil || report != nil {
+		t.Errorf("Run without its baseline returned %v, %v", report, err)
+	}
+	if keys := server.Keys(); keys != 0 {
+		t.Errorf("Run without its baseline wrote %d keys", keys)
+	}
+}
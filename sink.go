@@ -0,0 +1,144 @@
+package benchdis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const sinkTimeout = 10 * time.Second
+
+// ResultSink ships the final report of a run to an external system, alongside the report
+// rendered by the Reporter
+type ResultSink interface {
+	Name() string
+	Send(rpt *Report) error
+}
+
+// InfluxSink writes the results in InfluxDB line protocol to a file, or posts them to the
+// write endpoint of InfluxDB when the target is an http(s) url
+type InfluxSink struct {
+	Target string
+}
+
+var _ ResultSink = InfluxSink{}
+
+func (is InfluxSink) Name() string {
+	return "influxdb"
+}
+
+func (is InfluxSink) Send(rpt *Report) error {
+	lines := is.lineProtocol(rpt)
+	if !isHTTPURL(is.Target) {
+		return ioutil.WriteFile(is.Target, lines, 0644)
+	}
+	return postSink(is.Target, "text/plain; charset=utf-8", lines)
+}
+
+// lineProtocol renders one point per test, tagged with the test name, redis host, client
+// count, data size and the sweep level, if any
+func (is InfluxSink) lineProtocol(rpt *Report) []byte {
+	ts := rpt.Metadata.Timestamp.UnixNano()
+	buffer := new(bytes.Buffer)
+	for _, br := range rpt.Results {
+		conf := levelConfig(&rpt.Metadata.Config, br.Sweep)
+		tags := [][]string{
+			{"clients", fmt.Sprintf("%d", conf.NClients)},
+			{"data_size", fmt.Sprintf("%d", conf.ReqSize)},
+			{"host", conf.Host},
+			{"payload", br.Payload},
+			{"sweep", br.Sweep},
+			{"test", br.BenchTestName},
+		}
+		buffer.WriteString("benchdis")
+		for _, t := range tags {
+			if len(t[1]) > 0 {
+				buffer.WriteString("," + influxEscape(t[0]) + "=" + influxEscape(t[1]))
+			}
+		}
+
+		fields := []string{
+			fmt.Sprintf("qps=%g", br.QPS),
+			fmt.Sprintf("min=%g", br.MinLatency),
+			fmt.Sprintf("avg=%g", br.AvgLatency),
+			fmt.Sprintf("max=%g", br.MaxLatency),
+			fmt.Sprintf("errors=%di", br.ErrorCount),
+		}
+		pcts := make([]string, 0, len(br.Percentiles))
+		for _, k := range percentileKeys([]*BenchmarkResult{br}) {
+			pcts = append(pcts, fmt.Sprintf("%s=%g", influxEscape(k), br.Percentiles[k]))
+		}
+		fields = append(fields, pcts...)
+		buffer.WriteString(" " + strings.Join(fields, ","))
+		fmt.Fprintf(buffer, " %d\n", ts)
+	}
+	return buffer.Bytes()
+}
+
+// WebhookSink posts the report envelope as JSON to an http endpoint
+type WebhookSink struct {
+	URL string
+}
+
+var _ ResultSink = WebhookSink{}
+
+func (ws WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (ws WebhookSink) Send(rpt *Report) error {
+	j, err := json.Marshal(rpt)
+	if err != nil {
+		return err
+	}
+	return postSink(ws.URL, "application/json", j)
+}
+
+// GetSinks returns all the result sinks enabled in the config
+func GetSinks(conf *Config) []ResultSink {
+	sinks := make([]ResultSink, 0)
+	if len(conf.Pushgateway) > 0 {
+		sinks = append(sinks, PushgatewaySink{URL: conf.Pushgateway})
+	}
+	if len(conf.Influx) > 0 {
+		sinks = append(sinks, InfluxSink{Target: conf.Influx})
+	}
+	if len(conf.Webhook) > 0 {
+		sinks = append(sinks, WebhookSink{URL: conf.Webhook})
+	}
+	return sinks
+}
+
+func postSink(url, contentType string, body []byte) error {
+	return sendSink(http.MethodPost, url, contentType, body)
+}
+
+func sendSink(method, url, contentType string, body []byte) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := (&http.Client{Timeout: sinkTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded with %s", url, resp.Status)
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// influxEscape escapes commas, equals and spaces in tag keys, tag values and field keys
+func influxEscape(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
@@ -0,0 +1,87 @@
+package benchdis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxLineProtocol(t *testing.T) {
+	rpt := testReport()
+	rpt.Metadata.Timestamp = time.Unix(1700000000, 0)
+	rpt.Metadata.Config.Host = "bench host"
+	conf := rpt.Metadata.Config
+	lines := strings.Split(string(InfluxSink{}.lineProtocol(rpt)), "\n")
+	if len(lines) != 3 || lines[2] != "" {
+		t.Fatalf("Line protocol has lines %q, want one for each result", lines)
+	}
+	want := fmt.Sprintf("benchdis,clients=%d,data_size=%d,host=bench\\ host,payload=random,"+
+		"test=set qps=12000.5,min=0.1,avg=0.4,max=3.2,errors=0i,p50=0.3,p95=0.9,p99=1.5 "+
+		"1700000000000000000", conf.NClients, conf.ReqSize)
+	if lines[0] != want {
+		t.Errorf("Line of set = %s\nwant %s", lines[0], want)
+	}
+	if !strings.Contains(lines[1], "test=get ") || !strings.Contains(lines[1], "errors=12i") {
+		t.Errorf("Line of get = %s", lines[1])
+	}
+}
+
+func TestInfluxSink(t *testing.T) {
+	rpt := testReport()
+	path := filepath.Join(t.TempDir(), "results.lp")
+	if err := (InfluxSink{Target: path}).Send(rpt); err != nil {
+		t.Fatal(err)
+	}
+	written, _ := ioutil.ReadFile(path)
+	if string(written) != string(InfluxSink{}.lineProtocol(rpt)) {
+		t.Errorf("Influx file has %s", written)
+	}
+
+	server, requests := startSinkServer(t, http.StatusNoContent)
+	if err := (InfluxSink{Target: server.URL + "/api/v2/write"}).Send(rpt); err != nil {
+		t.Fatal(err)
+	}
+	reqs := requests()
+	if len(reqs) != 1 || reqs[0].Method != http.MethodPost ||
+		reqs[0].Path != "/api/v2/write" || reqs[0].Body != string(written) {
+		t.Errorf("Influx got requests %+v", reqs)
+	}
+
+	failing, _ := startSinkServer(t, http.StatusUnauthorized)
+	if err := (InfluxSink{Target: failing.URL}).Send(rpt); err == nil ||
+		!strings.Contains(err.Error(), "401") {
+		t.Errorf("Write answered with 401 returned %v", err)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	rpt := testReport()
+	server, requests := startSinkServer(t, http.StatusAccepted)
+	if err := (WebhookSink{URL: server.URL + "/hook"}).Send(rpt); err != nil {
+		t.Fatal(err)
+	}
+	reqs := requests()
+	if len(reqs) != 1 || reqs[0].Method != http.MethodPost || reqs[0].Path != "/hook" ||
+		reqs[0].ContentType != "application/json" {
+		t.Fatalf("Webhook got requests %+v", reqs)
+	}
+	var posted Report
+	if err := json.Unmarshal([]byte(reqs[0].Body), &posted); err != nil {
+		t.Fatalf("Webhook body does not parse: %v", err)
+	}
+	if len(posted.Results) != 2 || posted.Results[0].QPS != 12000.5 ||
+		posted.Comparison == nil || len(posted.SLOs) != 1 {
+		t.Errorf("Webhook got report %+v", posted)
+	}
+
+	failing, _ := startSinkServer(t, http.StatusInternalServerError)
+	if err := (WebhookSink{URL: failing.URL}).Send(rpt); err == nil ||
+		!strings.Contains(err.Error(), "500") {
+		t.Errorf("Webhook answered with 500 returned %v", err)
+	}
+}
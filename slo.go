@@ -0,0 +1,231 @@
+package benchdis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var sloPattern = regexp.MustCompile(
+	`^\s*([\w*-]+)\.([\w.]+)\s*(<=|>=|==|<|>)\s*([0-9]*\.?[0-9]+)\s*(ms|us|µs|s|%)?\s*$`)
+
+// latencyUnits converts latencies in the unit to milliseconds
+var latencyUnits = map[string]float64{"": 1, "ms": 1, "us": 0.001, "µs": 0.001, "s": 1000}
+
+// SLO is a service level objective asserted on a metric of a test, like get.p99 < 2ms. The
+// test * applies the assertion to every test.
+type SLO struct {
+	Assertion string
+	Test      string
+	Metric    string
+	Op        string
+	Threshold float64
+	Unit      string
+}
+
+// SLOResult is the outcome of asserting an SLO against the result of one test
+type SLOResult struct {
+	Assertion string  `json:"assertion" yaml:"assertion"`
+	Test      string  `json:"test" yaml:"test"`
+	Measured  float64 `json:"measured" yaml:"measured"`
+	Passed    bool    `json:"passed" yaml:"passed"`
+	Message   string  `json:"message" yaml:"message"`
+}
+
+type sloFile struct {
+	Assertions []string `yaml:"assertions"`
+}
+
+// LoadSLOs reads the SLO assertions from a yaml file, either as a list of assertions or as
+// a list under the key assertions
+func LoadSLOs(path string) ([]*SLO, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read SLOs from %s: %s", path, err.Error())
+	}
+	var assertions []string
+	if err := yaml.Unmarshal(content, &assertions); err != nil {
+		var sf sloFile
+		if err := yaml.Unmarshal(content, &sf); err != nil {
+			return nil, fmt.Errorf("Cannot parse SLOs from %s: %s", path, err.Error())
+		}
+		assertions = sf.Assertions
+	}
+	slos := make([]*SLO, 0, len(assertions))
+	for _, a := range assertions {
+		slo, err := ParseSLO(a)
+		if err != nil {
+			return nil, err
+		}
+		slos = append(slos, slo)
+	}
+	return slos, nil
+}
+
+// ParseSLO parses an assertion of the form <test>.<metric> <op> <value>[unit]. The metric is
+// one of qps, min, avg, max, a percentile like p99, errors or error_rate. Latencies are in
+// milliseconds unless a unit of us, ms or s is given.
+func ParseSLO(assertion string) (*SLO, error) {
+	m := sloPattern.FindStringSubmatch(assertion)
+	if m == nil {
+		return nil, fmt.Errorf("SLO %q is not valid, should be like get.p99 < 2ms", assertion)
+	}
+	threshold, _ := strconv.ParseFloat(m[4], 64)
+	slo := SLO{
+		Assertion: strings.TrimSpace(assertion),
+		Test:      strings.ToLower(m[1]),
+		Metric:    strings.ToLower(m[2]),
+		Op:        m[3],
+		Threshold: threshold,
+		Unit:      m[5],
+	}
+	switch {
+	case slo.Metric == "qps" || slo.Metric == "errors":
+		if len(slo.Unit) > 0 {
+			return nil, fmt.Errorf("SLO %q cannot have a unit for %s", assertion, slo.Metric)
+		}
+	case slo.Metric == "error_rate":
+		if len(slo.Unit) > 0 && slo.Unit != "%" {
+			return nil, fmt.Errorf("SLO %q should have error_rate in %%", assertion)
+		}
+	case slo.isLatency():
+		if slo.Unit == "%" {
+			return nil, fmt.Errorf("SLO %q cannot have latency in %%", assertion)
+		}
+		if p, ok := slo.percentile(); ok {
+			// p95.0 is measured as p95
+			slo.Metric = percentileKey(p)
+		}
+	default:
+		return nil, fmt.Errorf("SLO %q has unknown metric %s", assertion, slo.Metric)
+	}
+	if slo.Test != "*" && !searchInList(slo.Test, SupportedTests) {
+		return nil, fmt.Errorf("SLO %q has unknown test %s", assertion, slo.Test)
+	}
+	return &slo, nil
+}
+
+func (slo *SLO) isLatency() bool {
+	if slo.Metric == "min" || slo.Metric == "avg" || slo.Metric == "max" {
+		return true
+	}
+	_, err := percentileValue(slo.Metric)
+	return strings.HasPrefix(slo.Metric, "p") && err == nil
+}
+
+// percentile returns the percentile the SLO is on, if its metric is a percentile
+func (slo *SLO) percentile() (float64, bool) {
+	if !strings.HasPrefix(slo.Metric, "p") {
+		return 0, false
+	}
+	p, err := percentileValue(slo.Metric)
+	return p, err == nil
+}
+
+// sloPercentiles returns the percentiles the SLOs are on, which the run has to measure
+func sloPercentiles(slos []*SLO) []float64 {
+	pcts := make([]float64, 0)
+	for _, slo := range slos {
+		if p, ok := slo.percentile(); ok {
+			pcts = append(pcts, p)
+		}
+	}
+	return pcts
+}
+
+// measure returns the value of the metric of the SLO in the unit of the SLO. The error rate
+// is over the requests of all the runs of the test.
+func (slo *SLO) measure(br *BenchmarkResult, reqs int) (float64, bool) {
+	switch slo.Metric {
+	case "qps":
+		return br.QPS, true
+	case "errors":
+		return float64(br.ErrorCount), true
+	case "error_rate":
+		if reqs == 0 {
+			return 0, false
+		}
+		if len(br.Runs) > 1 {
+			reqs *= len(br.Runs)
+		}
+		return float64(br.ErrorCount) / float64(reqs) * 100, true
+	}
+	var ms float64
+	var ok bool
+	switch slo.Metric {
+	case "min":
+		ms, ok = br.MinLatency, true
+	case "avg":
+		ms, ok = br.AvgLatency, true
+	case "max":
+		ms, ok = br.MaxLatency, true
+	default:
+		ms, ok = br.Percentiles[slo.Metric]
+	}
+	return ms / latencyUnits[slo.Unit], ok
+}
+
+func (slo *SLO) holds(v float64) bool {
+	switch slo.Op {
+	case "<":
+		return v < slo.Threshold
+	case "<=":
+		return v <= slo.Threshold
+	case ">":
+		return v > slo.Threshold
+	case ">=":
+		return v >= slo.Threshold
+	default:
+		return v == slo.Threshold
+	}
+}
+
+// EvaluateSLOs asserts all the SLOs against the results of the tests they apply to. reqs is
+// the number of requests sent in each run of a test, used for the error rate. An SLO fails
+// when there is no result for its test, e.g. the test was not run or is misspelled.
+func EvaluateSLOs(slos []*SLO, brs []*BenchmarkResult, reqs int) []*SLOResult {
+	results := make([]*SLOResult, 0, len(slos))
+	for _, slo := range slos {
+		matched := false
+		for _, br := range brs {
+			if slo.Test != "*" && slo.Test != br.BenchTestName {
+				continue
+			}
+			matched = true
+			sr := SLOResult{Assertion: slo.Assertion, Test: br.BenchTestName}
+			name := fmt.Sprintf("%s.%s", br.BenchTestName, slo.Metric)
+			v, ok := slo.measure(br, reqs)
+			if !ok {
+				sr.Message = fmt.Sprintf("%s was not measured, expected %s %g%s", name, slo.Op,
+					slo.Threshold, slo.Unit)
+				results = append(results, &sr)
+				continue
+			}
+			sr.Measured = v
+			sr.Passed = slo.holds(v)
+			sr.Message = fmt.Sprintf("%s = %0.3f%s, expected %s %g%s", name, v, slo.Unit,
+				slo.Op, slo.Threshold, slo.Unit)
+			results = append(results, &sr)
+		}
+		if !matched {
+			results = append(results, &SLOResult{Assertion: slo.Assertion, Test: slo.Test,
+				Message: fmt.Sprintf("%s.%s was not measured, there is no result for %s",
+					slo.Test, slo.Metric, slo.Test)})
+		}
+	}
+	return results
+}
+
+// SLOFailed checks whether any of the SLOs failed
+func SLOFailed(results []*SLOResult) bool {
+	for _, sr := range results {
+		if !sr.Passed {
+			return true
+		}
+	}
+	return false
+}
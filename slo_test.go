@@ -0,0 +1,67 @@
+package benchdis
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSLOPercentilesAreMeasured(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slo.yaml")
+	content := "- get.p95.0 < 2ms\n- set.p99.9 < 5ms\n- \"*.qps > 1000\"\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := DefaultConfig()
+	conf.SLOFile = path
+	if _, err := conf.validateConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []float64{95, 99.9} {
+		if !searchInFloats(p, conf.Percentiles) {
+			t.Errorf("Percentiles %v do not have p%v of the SLOs", conf.Percentiles, p)
+		}
+	}
+	slos, _ := LoadSLOs(path)
+	if slos[0].Metric != "p95" {
+		t.Errorf("SLO on p95.0 is measured as %s, want p95", slos[0].Metric)
+	}
+}
+
+func TestSLOErrorRateOverRuns(t *testing.T) {
+	slo, err := ParseSLO("set.error_rate < 5%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 runs of 100 requests with 10 errors in all is an error rate of 3.33%
+	br := &BenchmarkResult{
+		BenchTestName: "set",
+		ErrorCount:    10,
+		Runs:          []*BenchmarkResult{{}, {}, {}},
+	}
+	results := EvaluateSLOs([]*SLO{slo}, []*BenchmarkResult{br}, 100)
+	if len(results) != 1 || !results[0].Passed {
+		t.Errorf("SLO results %+v, want the error rate of all the runs to pass", results[0])
+	}
+}
+
+func TestSLOWithoutResult(t *testing.T) {
+	brs := []*BenchmarkResult{{BenchTestName: "set", QPS: 5000}}
+	for _, assertion := range []string{"get.qps > 1000", "get.p99 < 1ms"} {
+		slo, err := ParseSLO(assertion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := EvaluateSLOs([]*SLO{slo}, brs, 100)
+		if len(results) != 1 || results[0].Passed ||
+			!strings.Contains(results[0].Message, "not measured") {
+			t.Errorf("SLO %s on a test without result = %+v, want it to fail", assertion,
+				results)
+		}
+	}
+	slo, _ := ParseSLO("*.qps > 1000")
+	if results := EvaluateSLOs([]*SLO{slo}, nil, 100); !SLOFailed(results) {
+		t.Errorf("SLO on all the tests of an empty run = %+v, want it to fail", results)
+	}
+}
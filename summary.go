@@ -0,0 +1,158 @@
+package benchdis
+
+import (
+	"math"
+	"strings"
+
+	"github.com/montanaflynn/stats"
+)
+
+// minRunsForOutliers is the least number of runs for which outlier detection across runs
+// gives meaningful results
+const minRunsForOutliers = 4
+
+// tCritical95 are the two tailed critical values of the Student's t distribution at 95%
+// confidence, indexed by degrees of freedom - 1
+var tCritical95 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262,
+	2.228, 2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086, 2.080, 2.074,
+	2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+
+// MetricSummary is the variance of one metric over the repeated runs of a benchmark test
+type MetricSummary struct {
+	Mean        float64 `json:"mean" yaml:"mean"`
+	StdDev      float64 `json:"stddev" yaml:"stddev"`
+	CILow       float64 `json:"ci95_low" yaml:"ci95_low"`
+	CIHigh      float64 `json:"ci95_high" yaml:"ci95_high"`
+	OutlierRuns []int   `json:"outlier_runs,omitempty" yaml:"outlier_runs,omitempty"`
+}
+
+// summarizeMetric computes the mean, sample standard deviation, 95% confidence interval and
+// the outlier runs (1 based) for the values of a metric across runs
+func summarizeMetric(values []float64) *MetricSummary {
+	data := stats.LoadRawData(values)
+	ms := MetricSummary{}
+	ms.Mean, _ = data.Mean()
+	if len(values) < 2 {
+		ms.CILow, ms.CIHigh = ms.Mean, ms.Mean
+		return &ms
+	}
+	ms.StdDev, _ = data.StandardDeviationSample()
+	t := 1.96
+	if df := len(values) - 1; df <= len(tCritical95) {
+		t = tCritical95[df-1]
+	}
+	margin := t * ms.StdDev / math.Sqrt(float64(len(values)))
+	ms.CILow, ms.CIHigh = ms.Mean-margin, ms.Mean+margin
+
+	if len(values) >= minRunsForOutliers {
+		outliers, _ := data.QuartileOutliers()
+		for i, v := range values {
+			if searchInFloats(v, outliers.Mild) || searchInFloats(v, outliers.Extreme) {
+				ms.OutlierRuns = append(ms.OutlierRuns, i+1)
+			}
+		}
+	}
+	return &ms
+}
+
+// Summarize combines the results of all the runs of the test into the BenchmarkResult. For
+// a single run the result is used as is. For repeated runs the QPS, average latency and
+// percentiles are the means over the runs, min and max are the extremes, errors and
+// histograms are summed. The variance of QPS and every percentile is recorded in the summary.
+// A run which was interrupted is left out, unless no run completed.
+func (b *Benchmark) Summarize() {
+	complete := make([]*BenchmarkResult, 0, len(b.runs))
+	for _, run := range b.runs {
+		if !run.Partial {
+			complete = append(complete, run)
+		}
+	}
+	if len(complete) > 0 {
+		b.runs = complete
+	}
+	if len(b.runs) == 0 {
+		return
+	}
+	if len(b.runs) == 1 {
+		b.BenchmarkResult = b.runs[0]
+		return
+	}
+	agg := BenchmarkResult{
+		BenchTestName: b.BenchTestName,
+		Payload:       b.runs[0].Payload,
+		MinLatency:    math.MaxFloat64,
+		Percentiles:   make(map[string]float64),
+		Summary:       make(map[string]*MetricSummary),
+		Runs:          b.runs,
+	}
+	metrics := make(map[string][]float64)
+	avgs := make([]float64, 0, len(b.runs))
+	hists := make([][]*HistogramBucket, 0, len(b.runs))
+	for i, run := range b.runs {
+		metrics["qps"] = append(metrics["qps"], run.QPS)
+		for k, v := range run.Percentiles {
+			metrics[k] = append(metrics[k], v)
+		}
+		avgs = append(avgs, run.AvgLatency)
+		agg.MinLatency = math.Min(agg.MinLatency, run.MinLatency)
+		agg.MaxLatency = math.Max(agg.MaxLatency, run.MaxLatency)
+		agg.ErrorCount += run.ErrorCount
+		for cat, es := range run.Errors {
+			if agg.Errors == nil {
+				agg.Errors = make(map[string]*ErrorStat)
+			}
+			if _, ok := agg.Errors[cat]; !ok {
+				agg.Errors[cat] = &ErrorStat{Sample: es.Sample}
+			}
+			agg.Errors[cat].Count += es.Count
+		}
+		for _, is := range run.TimeSeries {
+			is.Run = i + 1
+		}
+		agg.TimeSeries = append(agg.TimeSeries, run.TimeSeries...)
+		run.TimeSeries = nil
+		hists = append(hists, run.Histogram)
+		run.Histogram = nil
+	}
+	agg.Histogram = mergeHistograms(hists...)
+	if agg.MinLatency == math.MaxFloat64 {
+		agg.MinLatency = 0
+	}
+	agg.AvgLatency, _ = stats.Mean(avgs)
+	for k, values := range metrics {
+		ms := summarizeMetric(values)
+		agg.Summary[k] = ms
+		if k == "qps" {
+			agg.QPS = ms.Mean
+		} else {
+			agg.Percentiles[k] = ms.Mean
+		}
+	}
+	b.BenchmarkResult = &agg
+}
+
+// summaryKeys returns the metrics summarized in any of the results, QPS first followed by
+// the percentiles in order
+func summaryKeys(brs []*BenchmarkResult) []string {
+	keys := make([]string, 0)
+	for _, br := range brs {
+		if _, ok := br.Summary["qps"]; ok {
+			keys = append(keys, "qps")
+			break
+		}
+	}
+	for _, k := range percentileKeys(brs) {
+		for _, br := range brs {
+			if _, ok := br.Summary[k]; ok {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+// summaryHeader is the column header used for a summarized metric in the reports
+func summaryHeader(key string) string {
+	return strings.ToUpper(key)
+}
@@ -0,0 +1,231 @@
+package benchdis
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alexeyco/simpletable"
+)
+
+// SupportedSweeps are the parameters a sweep can vary, the number of clients, the pipeline
+// depth and the data size of the requests
+var SupportedSweeps []string = []string{"clients", "pipeline", "size"}
+
+// sweepHeaders are the column headers of the parameters in the sweep tables
+var sweepHeaders = map[string]string{"clients": "Clients", "pipeline": "Pipeline",
+	"size": "Data Size"}
+
+// SweepSpec is a sweep over the levels of a parameter, written like clients=1,2,4,8
+type SweepSpec struct {
+	Param  string
+	Levels []int
+}
+
+// ParseSweep parses a sweep of the form <param>=<level>,<level>,... with at least two
+// increasing levels
+func ParseSweep(spec string) (*SweepSpec, error) {
+	kv := strings.SplitN(spec, "=", 2)
+	if len(kv) != 2 {
+		return nil, fmt.Errorf("Sweep %q is not valid, should be like clients=1,2,4,8", spec)
+	}
+	ss := SweepSpec{Param: strings.ToLower(strings.TrimSpace(kv[0]))}
+	if !searchInList(ss.Param, SupportedSweeps) {
+		return nil, fmt.Errorf("Sweep %q has unknown parameter %s, should be one of %s", spec,
+			ss.Param, strings.Join(SupportedSweeps, ", "))
+	}
+	max := map[string]int{"clients": MaxNClients, "pipeline": MaxRequests, "size": MaxReqSize}
+	for _, l := range strings.Split(kv[1], ",") {
+		level, err := strconv.Atoi(strings.TrimSpace(l))
+		if err != nil || level < 1 || level > max[ss.Param] {
+			return nil, fmt.Errorf("Sweep %q has invalid level %s, should be from 1 to %d",
+				spec, l, max[ss.Param])
+		}
+		if len(ss.Levels) > 0 && level <= ss.Levels[len(ss.Levels)-1] {
+			return nil, fmt.Errorf("Sweep %q should have increasing levels", spec)
+		}
+		ss.Levels = append(ss.Levels, level)
+	}
+	if len(ss.Levels) < 2 {
+		return nil, fmt.Errorf("Sweep %q should have at least two levels", spec)
+	}
+	return &ss, nil
+}
+
+// apply returns a copy of the config with the parameter of the sweep set to the level
+func (ss *SweepSpec) apply(conf *Config, level int) *Config {
+	c := *conf
+	switch ss.Param {
+	case "clients":
+		c.NClients = level
+	case "pipeline":
+		c.Pipeline = level
+	case "size":
+		c.ReqSize = level
+	}
+	return &c
+}
+
+// label is the name of a level in the results, like clients=4
+func (ss *SweepSpec) label(level int) string {
+	return fmt.Sprintf("%s=%d", ss.Param, level)
+}
+
+// levelConfig returns a copy of the config with the sweep level of a result, like clients=4,
+// applied, or the config itself when the result is not from a sweep
+func levelConfig(conf *Config, level string) *Config {
+	kv := strings.SplitN(level, "=", 2)
+	if len(kv) != 2 {
+		return conf
+	}
+	l, err := strconv.Atoi(kv[1])
+	if err != nil {
+		return conf
+	}
+	return (&SweepSpec{Param: kv[0]}).apply(conf, l)
+}
+
+// SweepPoint is the throughput and latency of a test at one level of the sweep
+type SweepPoint struct {
+	Level   int     `json:"level" yaml:"level"`
+	QPS     float64 `json:"qps" yaml:"qps"`
+	P50     float64 `json:"p50" yaml:"p50"`
+	P99     float64 `json:"p99" yaml:"p99"`
+	Errors  int     `json:"errors" yaml:"errors"`
+	Partial bool    `json:"partial,omitempty" yaml:"partial,omitempty"`
+}
+
+// SweepCurve is the throughput against latency curve of a test over the levels of the sweep.
+// The knee is the first level where the p99 latency grows faster than the QPS, which is
+// where the server saturates. It is 0 if the server did not saturate.
+type SweepCurve struct {
+	Test   string        `json:"test" yaml:"test"`
+	Points []*SweepPoint `json:"points" yaml:"points"`
+	Knee   int           `json:"knee,omitempty" yaml:"knee,omitempty"`
+}
+
+// Sweep is the outcome of a sweep, with a curve for every test
+type Sweep struct {
+	Param  string        `json:"param" yaml:"param"`
+	Curves []*SweepCurve `json:"curves" yaml:"curves"`
+}
+
+// newSweep builds the curves of the tests from the results of all the levels, in the order
+// the levels ran
+func newSweep(ss *SweepSpec, tests []string, results []*BenchmarkResult) *Sweep {
+	sweep := Sweep{Param: ss.Param, Curves: make([]*SweepCurve, 0, len(tests))}
+	for _, test := range tests {
+		curve := SweepCurve{Test: test}
+		for _, level := range ss.Levels {
+			for _, br := range results {
+				if br.BenchTestName != test || br.Sweep != ss.label(level) {
+					continue
+				}
+				curve.Points = append(curve.Points, &SweepPoint{
+					Level:   level,
+					QPS:     br.QPS,
+					P50:     br.Percentiles[percentileKey(50)],
+					P99:     br.Percentiles[percentileKey(99)],
+					Errors:  br.ErrorCount,
+					Partial: br.Partial,
+				})
+			}
+		}
+		if len(curve.Points) > 0 {
+			curve.Knee = findKnee(curve.Points)
+			sweep.Curves = append(sweep.Curves, &curve)
+		}
+	}
+	return &sweep
+}
+
+// findKnee returns the first level where the relative growth of the p99 latency exceeds the
+// relative growth of the QPS, or 0 if there is none
+func findKnee(points []*SweepPoint) int {
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1], points[i]
+		if prev.QPS <= 0 || prev.P99 <= 0 {
+			continue
+		}
+		qpsGrowth := cur.QPS/prev.QPS - 1
+		p99Growth := cur.P99/prev.P99 - 1
+		if p99Growth > 0 && p99Growth > qpsGrowth {
+			return cur.Level
+		}
+	}
+	return 0
+}
+
+// growth is the change of a value from the previous point, in percent
+func growth(prev, cur float64) string {
+	if prev <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+0.1f%%", (cur/prev-1)*100)
+}
+
+// sweepTable renders the curves of a sweep, with the change of QPS and p99 from the previous
+// level and the knee of every test marked
+func (tr TableReporter) sweepTable(sweep *Sweep) string {
+	table := simpletable.New()
+	hdrStr := []string{"Test", sweepHeaders[sweep.Param], "QPS", "P50", "P99", "QPS Change",
+		"P99 Change", "Errors", ""}
+	header := make([]*simpletable.Cell, len(hdrStr))
+	for i, h := range hdrStr {
+		header[i] = &simpletable.Cell{Text: h}
+	}
+	table.Header = &simpletable.Header{Cells: header}
+	for _, curve := range sweep.Curves {
+		for i, p := range curve.Points {
+			qpsChange, p99Change := "", ""
+			if i > 0 {
+				qpsChange = growth(curve.Points[i-1].QPS, p.QPS)
+				p99Change = growth(curve.Points[i-1].P99, p.P99)
+			}
+			knee := ""
+			if p.Level == curve.Knee {
+				knee = "◀ knee"
+			}
+			table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+				{Text: curve.Test},
+				{Text: fmt.Sprintf("%d", p.Level), Align: simpletable.AlignRight},
+				tr.valueToCell(p.QPS),
+				tr.valueToCell(p.P50),
+				tr.valueToCell(p.P99),
+				{Text: qpsChange, Align: simpletable.AlignRight},
+				{Text: p99Change, Align: simpletable.AlignRight},
+				{Text: fmt.Sprintf("%d", p.Errors), Align: simpletable.AlignRight},
+				{Text: knee},
+			})
+		}
+	}
+	table.Footer = &simpletable.Footer{
+		Cells: []*simpletable.Cell{
+			{Span: len(hdrStr), Text: "The knee is the first level where P99 grows faster than QPS"},
+		},
+	}
+	table.SetStyle(simpletable.StyleUnicode)
+	return table.String()
+}
+
+// sweepTable renders the curves of a sweep as a markdown table with the knees in bold
+func (mr MarkdownReporter) sweepTable(sweep *Sweep) string {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("## Sweep over %s\n\n", sweep.Param))
+	mr.writeRow(&builder, []string{"Test", sweepHeaders[sweep.Param], "QPS (req/s)",
+		"P50 (ms)", "P99 (ms)", "Errors"})
+	mr.writeRow(&builder, []string{":---", "---:", "---:", "---:", "---:", "---:"})
+	for _, curve := range sweep.Curves {
+		for _, p := range curve.Points {
+			level := fmt.Sprintf("%d", p.Level)
+			if p.Level == curve.Knee {
+				level = fmt.Sprintf("**%d (knee)**", p.Level)
+			}
+			mr.writeRow(&builder, []string{curve.Test, level, fmt.Sprintf("%0.3f", p.QPS),
+				fmt.Sprintf("%0.3f", p.P50), fmt.Sprintf("%0.3f", p.P99),
+				fmt.Sprintf("%d", p.Errors)})
+		}
+	}
+	builder.WriteString("\nThe knee is the first level where P99 grows faster than QPS.\n")
+	return builder.String()
+}
@@ -0,0 +1,143 @@
+package benchdis
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSweep(t *testing.T) {
+	ss, err := ParseSweep("Clients = 1, 2,4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Param != "clients" || !reflect.DeepEqual(ss.Levels, []int{1, 2, 4}) {
+		t.Errorf("ParseSweep = %+v, want clients at 1, 2 and 4", *ss)
+	}
+	conf := DefaultConfig()
+	if c := ss.apply(&conf, 4); c.NClients != 4 || conf.NClients == 4 {
+		t.Errorf("apply set %d clients and left %d in the config", c.NClients, conf.NClients)
+	}
+
+	for _, spec := range []string{"clients", "threads=1,2", "clients=4", "clients=4,2",
+		"clients=1,1", "pipeline=0,1", "size=1,x"} {
+		if _, err := ParseSweep(spec); err == nil {
+			t.Errorf("ParseSweep(%q) did not fail", spec)
+		}
+	}
+}
+
+func TestFindKnee(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []*SweepPoint
+		want   int
+	}{
+		{"scales", []*SweepPoint{{Level: 1, QPS: 100, P99: 1}, {Level: 2, QPS: 200, P99: 1.2},
+			{Level: 4, QPS: 390, P99: 1.5}}, 0},
+		{"saturates", []*SweepPoint{{Level: 1, QPS: 100, P99: 1}, {Level: 2, QPS: 190, P99: 1.1},
+			{Level: 4, QPS: 210, P99: 2}, {Level: 8, QPS: 200, P99: 4}}, 4},
+		{"not measured", []*SweepPoint{{Level: 1}, {Level: 2, QPS: 100, P99: 1}}, 0},
+	}
+	for _, tt := range tests {
+		if got := findKnee(tt.points); got != tt.want {
+			t.Errorf("Knee of the curve which %s = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunnerSweep(t *testing.T) {
+	server := startFakeServer(t)
+	conf := testConfig(t, server)
+	conf.Tests = []string{"ping", "set"}
+	conf.Sweep = "clients=1,2,4"
+
+	report, err := NewRunner(conf).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 6 {
+		t.Fatalf("Sweep has %d results, want both tests at the 3 levels", len(report.Results))
+	}
+	if report.Results[0].Label() != "ping clients=1" {
+		t.Errorf("First result is labelled %q", report.Results[0].Label())
+	}
+	if report.Sweep == nil || len(report.Sweep.Curves) != 2 {
+		t.Fatalf("Sweep has curves %v, want one for each test", report.Sweep)
+	}
+	for _, curve := range report.Sweep.Curves {
+		levels := make([]int, 0, len(curve.Points))
+		for _, p := range curve.Points {
+			levels = append(levels, p.Level)
+			if p.QPS <= 0 || p.P99 <= 0 || p.Errors != 0 {
+				t.Errorf("%s at %d clients has %v QPS, %v p99 and %d errors", curve.Test,
+					p.Level, p.QPS, p.P99, p.Errors)
+			}
+		}
+		if !reflect.DeepEqual(levels, []int{1, 2, 4}) {
+			t.Errorf("Curve of %s has levels %v", curve.Test, levels)
+		}
+	}
+	if keys := server.Keys(); keys != 0 {
+		t.Errorf("%d keys are left behind by the sweep", keys)
+	}
+	for _, format := range []string{"table", "markdown"} {
+		if out := GetReporter(format).ReportResults(report); !strings.Contains(out, "Clients") {
+			t.Errorf("%s report has no sweep table:\n%s", format, out)
+		}
+	}
+}
+
+func TestSweepLevelsInOutputs(t *testing.T) {
+	conf := DefaultConfig()
+	conf.NClients = 50
+	conf.ReqSize = 16
+	results := []*BenchmarkResult{
+		{BenchTestName: "set", Sweep: "clients=1", QPS: 1000,
+			Percentiles: map[string]float64{"p99": 1}},
+		{BenchTestName: "set", Sweep: "clients=4", QPS: 3000,
+			Percentiles: map[string]float64{"p99": 2}},
+	}
+	rpt := NewReport(&conf, results, "", time.Unix(1700000000, 0))
+
+	lines := string(InfluxSink{}.lineProtocol(rpt))
+	for _, want := range []string{"benchdis,clients=1,data_size=16,", "benchdis,clients=4,",
+		",sweep=clients\\=4,test=set qps=3000"} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("Line protocol does not have %s:\n%s", want, lines)
+		}
+	}
+
+	metrics := new(bytes.Buffer)
+	writeResultMetrics(metrics, rpt)
+	for _, want := range []string{`benchdis_qps{test="set",sweep="clients=1"} 1000`,
+		`benchdis_latency_seconds{test="set",sweep="clients=4",percentile="99"} 0.002`} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("Metrics do not have %s:\n%s", want, metrics)
+		}
+	}
+
+	out := GoBenchReporter{}.ReportResults(rpt)
+	for _, want := range []string{"BenchmarkRedisSET/clients=1-1\t",
+		"BenchmarkRedisSET/clients=4-4\t"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("gobench report does not have %q:\n%s", want, out)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "sweep.csv")
+	if err := ioutil.WriteFile(path, []byte(GetReporter("csv").ReportResults(rpt)),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadResults(path)
+	if err != nil || len(loaded) != 2 || loaded[1].BenchTestName != "set" ||
+		loaded[1].Sweep != "clients=4" {
+		t.Errorf("csv report of the sweep loads back as %v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,172 @@
+package benchdis
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/montanaflynn/stats"
+)
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// IntervalStat is the aggregate of all the requests completed in one interval of a
+// benchmark test
+type IntervalStat struct {
+	Run    int     `json:"run,omitempty" yaml:"run,omitempty"`
+	Offset float64 `json:"offset" yaml:"offset"`
+	QPS    float64 `json:"qps" yaml:"qps"`
+	Errors int     `json:"errors" yaml:"errors"`
+	P50    float64 `json:"p50,omitempty" yaml:"p50,omitempty"`
+	P99    float64 `json:"p99,omitempty" yaml:"p99,omitempty"`
+	// Faults are the fault phases active during the interval
+	Faults []string `json:"faults,omitempty" yaml:"faults,omitempty"`
+}
+
+// intervalBucket collects the raw numbers of one client in one interval
+type intervalBucket struct {
+	requests  int
+	errors    int
+	latencies []float64
+}
+
+// markInterval records a request completed at the given time into the interval bucket of
+// the client. Each client owns its own slice of buckets so no locking is needed.
+func (b *Benchmark) markInterval(clientId int, at time.Time, latency float64, failed bool) {
+	if b.Interval <= 0 {
+		return
+	}
+	idx := int(at.Sub(b.Start) / b.Interval)
+	if idx < 0 {
+		idx = 0
+	}
+	bkts := b.buckets[clientId]
+	for len(bkts) <= idx {
+		bkts = append(bkts, &intervalBucket{})
+	}
+	bkt := bkts[idx]
+	if failed {
+		bkt.errors++
+	} else {
+		bkt.requests++
+		if b.Latency {
+			bkt.latencies = append(bkt.latencies, latency)
+		}
+	}
+	b.buckets[clientId] = bkts
+}
+
+// recordTimeSeries merges the interval buckets of all the clients into the time series of
+// the BenchmarkResult. The tail of the run after the last full interval is merged into that
+// interval, since the QPS over a sliver of time is mostly noise.
+func (b *Benchmark) recordTimeSeries() {
+	if b.Interval <= 0 {
+		return
+	}
+	total := b.End.Sub(b.Start)
+	n := int(total / b.Interval)
+	if n == 0 {
+		n = 1
+	}
+	series := make([]*IntervalStat, 0, n)
+	for i := 0; i < n; i++ {
+		offset := time.Duration(i) * b.Interval
+		width := b.Interval
+		last := i + 1
+		if i == n-1 {
+			width = total - offset
+			last = math.MaxInt32
+		}
+		reqs, errs := 0, 0
+		latencies := make([]float64, 0)
+		for _, bkts := range b.buckets {
+			for j := i; j < last && j < len(bkts); j++ {
+				reqs += bkts[j].requests
+				errs += bkts[j].errors
+				latencies = append(latencies, bkts[j].latencies...)
+			}
+		}
+		is := IntervalStat{
+			Offset: offset.Seconds(),
+			Errors: errs,
+			Faults: activeFaults(b.faults, offset, offset+width),
+		}
+		if width > 0 {
+			is.QPS = float64(reqs) / width.Seconds()
+		}
+		if len(latencies) > 0 {
+			is.P50, _ = stats.Percentile(latencies, 50)
+			is.P99, _ = stats.Percentile(latencies, 99)
+		}
+		series = append(series, &is)
+	}
+	b.TimeSeries = series
+}
+
+// sparkline renders the QPS of the time series as a string of unicode block characters
+func sparkline(series []*IntervalStat) string {
+	if len(series) == 0 {
+		return ""
+	}
+	min, max := math.MaxFloat64, 0.0
+	for _, is := range series {
+		min = math.Min(min, is.QPS)
+		max = math.Max(max, is.QPS)
+	}
+	builder := strings.Builder{}
+	for _, is := range series {
+		tick := 0
+		if max > min {
+			tick = int((is.QPS - min) / (max - min) * float64(len(sparkTicks)-1))
+		}
+		builder.WriteRune(sparkTicks[tick])
+	}
+	return builder.String()
+}
+
+type timeSeriesExport struct {
+	Test   string          `json:"test"`
+	Series []*IntervalStat `json:"series"`
+}
+
+// WriteTimeSeries exports the time series of all the results to a file. The format is
+// chosen from the file extension, .json writes JSON and everything else writes CSV.
+func WriteTimeSeries(path string, brs []*BenchmarkResult) error {
+	var out []byte
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		exports := make([]timeSeriesExport, 0, len(brs))
+		for _, br := range brs {
+			exports = append(exports, timeSeriesExport{Test: br.BenchTestName, Series: br.TimeSeries})
+		}
+		j, err := json.MarshalIndent(exports, "", "  ")
+		if err != nil {
+			return err
+		}
+		out = j
+	} else {
+		buffer := new(bytes.Buffer)
+		csvWr := csv.NewWriter(buffer)
+		csvWr.Write([]string{"Test", "Run", "Offset", "QPS", "Errors", "P50", "P99", "Faults"})
+		for _, br := range brs {
+			for _, is := range br.TimeSeries {
+				csvWr.Write([]string{br.BenchTestName,
+					fmt.Sprintf("%d", is.Run),
+					fmt.Sprintf("%0.3f", is.Offset),
+					fmt.Sprintf("%0.3f", is.QPS),
+					fmt.Sprintf("%d", is.Errors),
+					fmt.Sprintf("%0.3f", is.P50),
+					fmt.Sprintf("%0.3f", is.P99),
+					strings.Join(is.Faults, "; ")})
+			}
+		}
+		csvWr.Flush()
+		out = buffer.Bytes()
+	}
+	return ioutil.WriteFile(path, out, 0644)
+}
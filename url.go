@@ -0,0 +1,126 @@
+package benchdis
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// redisURL are the connection options parsed from a redis:// or rediss:// url. HasDatabase
+// tells if the url sets the database in its path.
+type redisURL struct {
+	Host        string
+	Port        int
+	Username    string
+	Password    string
+	Database    int
+	HasDatabase bool
+	TLS         bool
+}
+
+// parseRedisURL parses a url of the form redis://[user[:password]@]host[:port][/db], with
+// rediss:// for TLS. The host defaults to localhost and the port to 6379 as in redigo.
+func parseRedisURL(raw string) (*redisURL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Redis url is not valid: %s", err.Error())
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("Redis url scheme %s is not valid, should be redis or rediss",
+			u.Scheme)
+	}
+	ru := redisURL{Host: u.Hostname(), Port: 6379, TLS: u.Scheme == "rediss"}
+	if len(ru.Host) == 0 {
+		ru.Host = "localhost"
+	}
+	if len(u.Port()) > 0 {
+		if ru.Port, err = strconv.Atoi(u.Port()); err != nil {
+			return nil, fmt.Errorf("Redis url port %s is not valid", u.Port())
+		}
+	}
+	if u.User != nil {
+		ru.Username = u.User.Username()
+		ru.Password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); len(db) > 0 {
+		if ru.Database, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("Redis url database %s is not valid", db)
+		}
+		ru.HasDatabase = true
+	}
+	return &ru, nil
+}
+
+// applyURL sets the connection options of the config from its url. An option which is set
+// explicitly by a flag and differs from the url is reported as a conflict.
+func (conf *Config) applyURL(explicit func(flag string) bool) error {
+	ru, err := parseRedisURL(conf.URL)
+	if err != nil {
+		return err
+	}
+	conflicts := make([]string, 0)
+	check := func(flag string, set bool, value, fromURL interface{}) {
+		if set && value != fromURL {
+			conflicts = append(conflicts, fmt.Sprintf("--%s %v", flag, value))
+		}
+	}
+	check("host", explicit("host"), conf.Host, ru.Host)
+	check("port", explicit("port"), conf.Port, ru.Port)
+	check("db", explicit("db") && ru.HasDatabase, conf.Database, ru.Database)
+	check("user", explicit("user") && len(ru.Username) > 0, conf.Username, ru.Username)
+	check("tls", explicit("tls"), conf.TLS, ru.TLS)
+	if len(ru.Password) > 0 && (explicit("auth") || explicit("auth-file")) &&
+		conf.Auth != ru.Password {
+		conflicts = append(conflicts, "--auth")
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("Redis url %s conflicts with %s", redactURL(conf.URL),
+			strings.Join(conflicts, ", "))
+	}
+
+	conf.Host, conf.Port, conf.TLS = ru.Host, ru.Port, ru.TLS
+	if ru.HasDatabase {
+		conf.Database = ru.Database
+	}
+	if len(ru.Username) > 0 {
+		conf.Username = ru.Username
+	}
+	if len(ru.Password) > 0 {
+		conf.Auth = ru.Password
+	}
+	return nil
+}
+
+// urlHasPassword tells if a redis url carries a password
+func urlHasPassword(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return false
+	}
+	_, ok := u.User.Password()
+	return ok
+}
+
+// redactURL masks the password in a url
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// redisAddress is the host:port to connect to
+func redisAddress(conf *Config) string {
+	return net.JoinHostPort(conf.Host, strconv.Itoa(conf.Port))
+}
+
+// dialAddress is the address the clients dial, which is the fault proxy when there is one
+func dialAddress(conf *Config) string {
+	if len(conf.proxyAddr) > 0 {
+		return conf.proxyAddr
+	}
+	return redisAddress(conf)
+}